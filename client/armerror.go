@@ -0,0 +1,152 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Codes of the ARM authorization errors
+const (
+	ARMCodeAuthenticationFailed      = "AuthenticationFailed"
+	ARMCodeAuthorizationFailed       = "AuthorizationFailed"
+	ARMCodeLinkedAuthorizationFailed = "LinkedAuthorizationFailed"
+)
+
+// ARMErrorResponse is the ARM error envelope
+type ARMErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// LinkedAccessCheck is an access check on a scope linked to the request,
+// e.g. the subnet a network interface joins
+type LinkedAccessCheck struct {
+	Scope    string
+	Actions  []ActionInfo
+	Response *AuthorizationDecisionResponse
+}
+
+// ARMErrorOptions configures NewAuthorizationFailedError
+type ARMErrorOptions struct {
+	// ClientName is how the caller is named in the message, e.g. its UPN.
+	// The object id of the subject is used when empty.
+	ClientName string
+	// LinkedChecks are the access checks of the linked scopes of the request
+	LinkedChecks []LinkedAccessCheck
+	// Explain configures the explanations in the error details
+	Explain *ExplainOptions
+}
+
+// NewAuthorizationFailedError builds the ARM error envelope of authzReq and
+// its decisions res, in the format ARM returns with a 403 status. The first
+// failed action in the order of the request is named in the message: a deny
+// assignment yields a DenyAssignmentAuthorizationFailed error, a missing
+// role assignment an AuthorizationFailed error. When every action is allowed
+// but a linked check fails, a LinkedAuthorizationFailed error is returned.
+// The details explain every failed decision. It returns nil when everything
+// is allowed.
+func NewAuthorizationFailedError(authzReq AuthorizationRequest, res *AuthorizationDecisionResponse, options *ARMErrorOptions) *ARMErrorResponse {
+	if options == nil {
+		options = &ARMErrorOptions{}
+	}
+	objectId := authzReq.Subject.Attributes.ObjectId
+	clientName := options.ClientName
+	if clientName == "" {
+		clientName = objectId
+	}
+	client := fmt.Sprintf("The client '%s' with object id '%s'", clientName, objectId)
+	scope := authzReq.Resource.Id
+
+	result := NewDecisionResult(authzReq.Actions, res)
+	details := failedDetails(result, options.Explain)
+	for _, action := range result.Actions {
+		decision, ok := result.Decision(action.Id)
+		switch {
+		case ok && decision.AccessDecision == Allowed:
+			continue
+		case ok && decision.AccessDecision == Denied:
+			return &ARMErrorResponse{Error: ErrorDetail{
+				Code: ExplanationCodeDenied,
+				Message: fmt.Sprintf("%s has permission to perform action '%s' on scope '%s'; however, the access is denied because of the deny assignment with Id '%s'.",
+					client, action.Id, scope, decision.DenyAssignment.Id),
+				Target:  action.Id,
+				Details: details,
+			}}
+		default:
+			return &ARMErrorResponse{Error: ErrorDetail{
+				Code: ARMCodeAuthorizationFailed,
+				Message: fmt.Sprintf("%s does not have authorization to perform action '%s' over scope '%s' or the scope is invalid. If access was recently granted, please refresh your credentials.",
+					client, action.Id, scope),
+				Target:  action.Id,
+				Details: details,
+			}}
+		}
+	}
+
+	var linkedActions, linkedScopes []string
+	for _, linked := range options.LinkedChecks {
+		linkedResult := NewDecisionResult(linked.Actions, linked.Response)
+		if linkedResult.AllAllowed() {
+			continue
+		}
+		for _, action := range linked.Actions {
+			if decision, ok := linkedResult.Decision(action.Id); !ok || decision.AccessDecision != Allowed {
+				linkedActions = append(linkedActions, action.Id)
+				linkedScopes = append(linkedScopes, linked.Scope)
+			}
+		}
+		details = append(details, failedDetails(linkedResult, options.Explain)...)
+	}
+	if len(linkedActions) == 0 {
+		return nil
+	}
+	var action string
+	if len(authzReq.Actions) > 0 {
+		action = authzReq.Actions[0].Id
+	}
+	return &ARMErrorResponse{Error: ErrorDetail{
+		Code: ARMCodeLinkedAuthorizationFailed,
+		Message: fmt.Sprintf("%s has permission to perform action '%s' on scope '%s'; however, it does not have permission to perform action(s) '%s' on the linked scope(s) '%s' (respectively) or the linked scope(s) are invalid.",
+			client, action, scope, strings.Join(linkedActions, ","), strings.Join(linkedScopes, ",")),
+		Target:  action,
+		Details: details,
+	}}
+}
+
+// failedDetails returns the error details of the failed actions of result
+func failedDetails(result *DecisionResult, options *ExplainOptions) []ErrorDetail {
+	var details []ErrorDetail
+	for _, action := range result.Actions {
+		decision, ok := result.Decision(action.Id)
+		if !ok {
+			decision = &AuthorizationDecision{ActionId: action.Id, AccessDecision: NotAllowed}
+		}
+		if decision.AccessDecision == Allowed {
+			continue
+		}
+		details = append(details, ExplainDecision(*decision, options).ErrorDetail())
+	}
+	return details
+}
+
+// Write writes the error to w with a 403 status
+func (e *ARMErrorResponse) Write(w http.ResponseWriter) error {
+	return e.WriteStatus(w, http.StatusForbidden)
+}
+
+// WriteStatus writes the error to w with status, e.g. a 401 for an
+// AuthenticationFailed error
+func (e *ARMErrorResponse) WriteStatus(w http.ResponseWriter, status int) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error while marshaling the ARM error, err: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
@@ -0,0 +1,115 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewAuthorizationFailedError(t *testing.T) {
+	scope := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic"
+	subnet := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/default"
+	authzReq := AuthorizationRequest{
+		Subject:  SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid"}},
+		Actions:  []ActionInfo{{Id: "Microsoft.Network/networkInterfaces/write"}, {Id: "Microsoft.Network/networkInterfaces/read"}},
+		Resource: ResourceInfo{Id: scope},
+	}
+	allowed := &AuthorizationDecisionResponse{Value: []AuthorizationDecision{
+		{ActionId: "Microsoft.Network/networkInterfaces/write", AccessDecision: Allowed},
+		{ActionId: "Microsoft.Network/networkInterfaces/read", AccessDecision: Allowed},
+	}}
+
+	for _, tt := range []struct {
+		name        string
+		res         *AuthorizationDecisionResponse
+		options     *ARMErrorOptions
+		wantNil     bool
+		wantCode    string
+		wantMessage string
+		wantDetails int
+	}{
+		{
+			name:    "pass - everything allowed",
+			res:     allowed,
+			wantNil: true,
+		},
+		{
+			name: "pass - missing role assignment",
+			res: &AuthorizationDecisionResponse{Value: []AuthorizationDecision{
+				{ActionId: "Microsoft.Network/networkInterfaces/write", AccessDecision: NotAllowed},
+			}},
+			options:     &ARMErrorOptions{ClientName: "user@contoso.com"},
+			wantCode:    ARMCodeAuthorizationFailed,
+			wantMessage: "The client 'user@contoso.com' with object id 'oid' does not have authorization to perform action 'Microsoft.Network/networkInterfaces/write' over scope '" + scope + "' or the scope is invalid. If access was recently granted, please refresh your credentials.",
+			wantDetails: 2,
+		},
+		{
+			name: "pass - deny assignment",
+			res: &AuthorizationDecisionResponse{Value: []AuthorizationDecision{
+				{ActionId: "Microsoft.Network/networkInterfaces/write", AccessDecision: Denied, DenyAssignment: RoleDefinition{Id: "da1"}},
+				{ActionId: "Microsoft.Network/networkInterfaces/read", AccessDecision: Allowed},
+			}},
+			wantCode:    ExplanationCodeDenied,
+			wantMessage: "The client 'oid' with object id 'oid' has permission to perform action 'Microsoft.Network/networkInterfaces/write' on scope '" + scope + "'; however, the access is denied because of the deny assignment with Id 'da1'.",
+			wantDetails: 1,
+		},
+		{
+			name: "pass - linked access check",
+			res:  allowed,
+			options: &ARMErrorOptions{LinkedChecks: []LinkedAccessCheck{{
+				Scope:   subnet,
+				Actions: []ActionInfo{{Id: "Microsoft.Network/virtualNetworks/subnets/join/action"}},
+				Response: &AuthorizationDecisionResponse{Value: []AuthorizationDecision{
+					{ActionId: "Microsoft.Network/virtualNetworks/subnets/join/action", AccessDecision: NotAllowed},
+				}},
+			}}},
+			wantCode:    ARMCodeLinkedAuthorizationFailed,
+			wantMessage: "The client 'oid' with object id 'oid' has permission to perform action 'Microsoft.Network/networkInterfaces/write' on scope '" + scope + "'; however, it does not have permission to perform action(s) 'Microsoft.Network/virtualNetworks/subnets/join/action' on the linked scope(s) '" + subnet + "' (respectively) or the linked scope(s) are invalid.",
+			wantDetails: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			armErr := NewAuthorizationFailedError(authzReq, tt.res, tt.options)
+			if tt.wantNil {
+				if armErr != nil {
+					t.Errorf("expected no error but got %v", armErr)
+				}
+				return
+			}
+			if armErr == nil {
+				t.Fatal("expected an error but got nil")
+			}
+			if armErr.Error.Code != tt.wantCode {
+				t.Errorf("expected code %s but got %s", tt.wantCode, armErr.Error.Code)
+			}
+			if armErr.Error.Message != tt.wantMessage {
+				t.Errorf("expected message\n%s\nbut got\n%s", tt.wantMessage, armErr.Error.Message)
+			}
+			if len(armErr.Error.Details) != tt.wantDetails {
+				t.Errorf("expected %d details but got %v", tt.wantDetails, armErr.Error.Details)
+			}
+		})
+	}
+}
+
+func TestARMErrorResponseWrite(t *testing.T) {
+	armErr := &ARMErrorResponse{Error: ErrorDetail{Code: ARMCodeAuthorizationFailed, Message: "message"}}
+	w := httptest.NewRecorder()
+	if err := armErr.Write(w); err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 but got %d", w.Code)
+	}
+	var got ARMErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if got.Error.Code != ARMCodeAuthorizationFailed || got.Error.Message != "message" {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+}
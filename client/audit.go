@@ -0,0 +1,252 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Headers the correlation ID of an audit event is read from, in order of preference
+const (
+	headerCorrelationRequestId = "x-ms-correlation-request-id"
+	headerClientRequestId      = "x-ms-client-request-id"
+)
+
+// AuditEvent is the structured record of a single authorization decision.
+// It never contains the caller's token; the group list is only included
+// when AuditRedaction.IncludeGroups is set. A failed CheckAccess call has an
+// event per requested action, with Error set and no AccessDecision.
+type AuditEvent struct {
+	Time                 time.Time      `json:"time"`
+	CorrelationId        string         `json:"correlationId,omitempty"`
+	SubjectObjectId      string         `json:"subjectObjectId"`
+	SubjectTenantId      string         `json:"subjectTenantId,omitempty"`
+	SubjectApplicationId string         `json:"subjectApplicationId,omitempty"`
+	SubjectGroups        []string       `json:"subjectGroups,omitempty"`
+	SubjectGroupCount    int            `json:"subjectGroupCount,omitempty"`
+	ResourceId           string         `json:"resourceId"`
+	ActionId             string         `json:"actionId"`
+	IsDataAction         bool           `json:"isDataAction,omitempty"`
+	AccessDecision       AccessDecision `json:"accessDecision"`
+	RoleAssignmentId     string         `json:"roleAssignmentId,omitempty"`
+	DenyAssignmentId     string         `json:"denyAssignmentId,omitempty"`
+	LatencyMs            int64          `json:"latencyMs"`
+	// Degraded is set when the decision was only obtained after retrying the PDP
+	Degraded bool `json:"degraded,omitempty"`
+	// Error is the error of a failed CheckAccess call
+	Error string `json:"error,omitempty"`
+}
+
+// AuditSink receives the audit events of a client. Implementations must be
+// safe for concurrent use.
+type AuditSink interface {
+	WriteAuditEvent(ctx context.Context, event AuditEvent) error
+}
+
+// AuditSinkFunc adapts a function to an AuditSink
+type AuditSinkFunc func(ctx context.Context, event AuditEvent) error
+
+func (f AuditSinkFunc) WriteAuditEvent(ctx context.Context, event AuditEvent) error {
+	return f(ctx, event)
+}
+
+// AuditRedaction controls which sensitive fields are kept in audit events
+type AuditRedaction struct {
+	// IncludeGroups keeps the subject's group list; only the number of
+	// groups is recorded otherwise.
+	IncludeGroups bool
+
+	// Redact, if set, is applied to every event after the built-in redaction
+	// and before it is written to the sinks.
+	Redact func(*AuditEvent)
+}
+
+// AuditOptions configures the decision audit of a client
+type AuditOptions struct {
+	// Sinks receive every audit event. Auditing is disabled when empty.
+	Sinks []AuditSink
+
+	// Redaction controls which sensitive fields are kept
+	Redaction AuditRedaction
+
+	// OnError is called when a sink fails to write an event. A failing sink
+	// never fails the CheckAccess call.
+	OnError func(error)
+}
+
+// auditor fans the audit events of a CheckAccess call out to the sinks
+type auditor struct {
+	options AuditOptions
+}
+
+// newAuditor returns nil when options has no sinks
+func newAuditor(options *AuditOptions) *auditor {
+	if options == nil || len(options.Sinks) == 0 {
+		return nil
+	}
+	return &auditor{options: *options}
+}
+
+// record writes an event for each decision in res, or for each requested
+// action with checkErr when the call failed
+func (a *auditor) record(ctx context.Context, authzReq AuthorizationRequest, res *AuthorizationDecisionResponse, checkErr error, raw *http.Response, latency time.Duration, degraded bool) {
+	if a == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	attrs := authzReq.Subject.Attributes
+	isDataAction := map[string]bool{}
+	for _, action := range authzReq.Actions {
+		isDataAction[action.Id] = action.IsDataAction
+	}
+
+	var decisions []AuthorizationDecision
+	var errorMessage string
+	switch {
+	case checkErr != nil:
+		errorMessage = checkErr.Error()
+		for _, action := range authzReq.Actions {
+			decisions = append(decisions, AuthorizationDecision{ActionId: action.Id, IsDataAction: action.IsDataAction})
+		}
+	case res != nil:
+		decisions = res.Value
+	}
+
+	for _, decision := range decisions {
+		event := AuditEvent{
+			Time:                 now,
+			CorrelationId:        correlationId(raw),
+			SubjectObjectId:      attrs.ObjectId,
+			SubjectTenantId:      attrs.TenantId,
+			SubjectApplicationId: attrs.ApplicationId,
+			SubjectGroupCount:    len(attrs.Groups),
+			ResourceId:           authzReq.Resource.Id,
+			ActionId:             decision.ActionId,
+			IsDataAction:         decision.IsDataAction || isDataAction[decision.ActionId],
+			AccessDecision:       decision.AccessDecision,
+			RoleAssignmentId:     decision.RoleAssignment.Id,
+			DenyAssignmentId:     decision.DenyAssignment.Id,
+			LatencyMs:            latency.Milliseconds(),
+			Degraded:             degraded,
+			Error:                errorMessage,
+		}
+		if a.options.Redaction.IncludeGroups {
+			// Redact may change the groups of an event, not the ones of the request
+			event.SubjectGroups = append([]string(nil), attrs.Groups...)
+		}
+		if a.options.Redaction.Redact != nil {
+			a.options.Redaction.Redact(&event)
+		}
+
+		for _, sink := range a.options.Sinks {
+			if err := sink.WriteAuditEvent(ctx, event); err != nil && a.options.OnError != nil {
+				a.options.OnError(fmt.Errorf("error while writing audit event, err: %w", err))
+			}
+		}
+	}
+}
+
+// correlationId returns the correlation ID of a PDP exchange
+func correlationId(raw *http.Response) string {
+	if raw == nil {
+		return ""
+	}
+	if id := raw.Header.Get(headerCorrelationRequestId); id != "" {
+		return id
+	}
+	if raw.Request != nil {
+		return raw.Request.Header.Get(headerClientRequestId)
+	}
+	return ""
+}
+
+// slogAuditSink writes audit events to a slog.Logger
+type slogAuditSink struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// NewSlogAuditSink returns an AuditSink logging every event at level to logger
+func NewSlogAuditSink(logger *slog.Logger, level slog.Level) *slogAuditSink {
+	return &slogAuditSink{logger: logger, level: level}
+}
+
+func (s *slogAuditSink) WriteAuditEvent(ctx context.Context, event AuditEvent) error {
+	attrs := []slog.Attr{
+		slog.Time("time", event.Time),
+		slog.String("correlationId", event.CorrelationId),
+		slog.String("subjectObjectId", event.SubjectObjectId),
+		slog.String("subjectTenantId", event.SubjectTenantId),
+		slog.String("subjectApplicationId", event.SubjectApplicationId),
+		slog.Int("subjectGroupCount", event.SubjectGroupCount),
+		slog.String("resourceId", event.ResourceId),
+		slog.String("actionId", event.ActionId),
+		slog.Bool("isDataAction", event.IsDataAction),
+		slog.String("accessDecision", string(event.AccessDecision)),
+		slog.String("roleAssignmentId", event.RoleAssignmentId),
+		slog.String("denyAssignmentId", event.DenyAssignmentId),
+		slog.Int64("latencyMs", event.LatencyMs),
+		slog.Bool("degraded", event.Degraded),
+	}
+	if event.SubjectGroups != nil {
+		attrs = append(attrs, slog.Any("subjectGroups", event.SubjectGroups))
+	}
+	if event.Error != "" {
+		attrs = append(attrs, slog.String("error", event.Error))
+	}
+	s.logger.LogAttrs(ctx, s.level, "authorization decision", attrs...)
+	return nil
+}
+
+// jsonLinesAuditSink writes audit events as JSON lines
+type jsonLinesAuditSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesAuditSink returns an AuditSink writing one JSON object per line to w
+func NewJSONLinesAuditSink(w io.Writer) *jsonLinesAuditSink {
+	return &jsonLinesAuditSink{w: w}
+}
+
+// NewJSONLinesFileAuditSink returns an AuditSink appending one JSON object
+// per line to the file at path. Close must be called to release the file.
+func NewJSONLinesFileAuditSink(path string) (*jsonLinesAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonLinesAuditSink{w: f, closer: f}, nil
+}
+
+func (s *jsonLinesAuditSink) WriteAuditEvent(ctx context.Context, event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close closes the underlying file, if the sink owns one
+func (s *jsonLinesAuditSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
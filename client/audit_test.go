@@ -0,0 +1,230 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+func TestCheckAccessAudit(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	scope := "https://authorization.azure.net/.default"
+	resourceId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg"
+	body := `{"value":[
+		{"actionId":"read","accessDecision":"Allowed","roleAssignment":{"id":"ra1"}},
+		{"actionId":"write","accessDecision":"Denied","denyAssignment":{"id":"da1"}}]}`
+	authzReq := AuthorizationRequest{
+		Subject: SubjectInfo{Attributes: SubjectAttributes{
+			ObjectId:      "oid",
+			TenantId:      "tid",
+			ApplicationId: "appid",
+			Groups:        []string{"group1", "group2"},
+		}},
+		Actions:  []ActionInfo{{Id: "read"}, {Id: "write", IsDataAction: true}},
+		Resource: ResourceInfo{Id: resourceId},
+	}
+
+	for _, tt := range []struct {
+		name       string
+		redaction  AuditRedaction
+		wantEvents []AuditEvent
+	}{
+		{
+			name: "pass - groups are redacted by default",
+			wantEvents: []AuditEvent{
+				{SubjectObjectId: "oid", SubjectTenantId: "tid", SubjectApplicationId: "appid", SubjectGroupCount: 2, ResourceId: resourceId, ActionId: "read", AccessDecision: Allowed, RoleAssignmentId: "ra1"},
+				{SubjectObjectId: "oid", SubjectTenantId: "tid", SubjectApplicationId: "appid", SubjectGroupCount: 2, ResourceId: resourceId, ActionId: "write", IsDataAction: true, AccessDecision: Denied, DenyAssignmentId: "da1"},
+			},
+		},
+		{
+			name: "pass - groups are kept and custom redaction applies",
+			redaction: AuditRedaction{
+				IncludeGroups: true,
+				Redact:        func(e *AuditEvent) { e.SubjectApplicationId = "" },
+			},
+			wantEvents: []AuditEvent{
+				{SubjectObjectId: "oid", SubjectTenantId: "tid", SubjectGroups: []string{"group1", "group2"}, SubjectGroupCount: 2, ResourceId: resourceId, ActionId: "read", AccessDecision: Allowed, RoleAssignmentId: "ra1"},
+				{SubjectObjectId: "oid", SubjectTenantId: "tid", SubjectGroups: []string{"group1", "group2"}, SubjectGroupCount: 2, ResourceId: resourceId, ActionId: "write", IsDataAction: true, AccessDecision: Denied, DenyAssignmentId: "da1"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []AuditEvent
+			var sinkErrs []error
+			client, err := NewRemotePDPClientWithOptions(endpoint, scope, test.FakeCredential{}, &ClientOptions{
+				ClientOptions: azcore.ClientOptions{Transport: test.CreateTransport(http.StatusOK, body)},
+				Audit: &AuditOptions{
+					Sinks: []AuditSink{
+						AuditSinkFunc(func(ctx context.Context, event AuditEvent) error {
+							events = append(events, event)
+							return nil
+						}),
+						AuditSinkFunc(func(ctx context.Context, event AuditEvent) error {
+							return errors.New("sink failure")
+						}),
+					},
+					Redaction: tt.redaction,
+					OnError:   func(err error) { sinkErrs = append(sinkErrs, err) },
+				},
+			})
+			if err != nil {
+				t.Fatalf("Unable to create a new PDP client: %v", err)
+			}
+
+			if _, err := client.CheckAccess(context.Background(), authzReq); err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			for _, e := range events {
+				if e.CorrelationId == "" {
+					t.Errorf("expected a correlation ID on event %v", e)
+				}
+			}
+			if diff := cmp.Diff(tt.wantEvents, events, cmpopts.IgnoreFields(AuditEvent{}, "Time", "CorrelationId", "LatencyMs")); diff != "" {
+				t.Errorf("incorrect audit events: %v", diff)
+			}
+			if len(sinkErrs) != 2 {
+				t.Errorf("expected 2 sink errors but got %d", len(sinkErrs))
+			}
+		})
+	}
+}
+
+func TestCheckAccessAuditTokenSubjectAndErrors(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	scope := "https://authorization.azure.net/.default"
+	resourceId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg"
+
+	for _, tt := range []struct {
+		name       string
+		statusCode int
+		body       string
+		wantEvents []AuditEvent
+	}{
+		{
+			name:       "pass - subject from the token claims",
+			statusCode: http.StatusOK,
+			body:       `{"value":[{"actionId":"read","accessDecision":"Allowed"}]}`,
+			wantEvents: []AuditEvent{
+				{SubjectObjectId: "oid", SubjectTenantId: "tid", SubjectApplicationId: "azp", SubjectGroups: []string{"redacted"}, SubjectGroupCount: 1, ResourceId: resourceId, ActionId: "read", AccessDecision: Allowed},
+			},
+		},
+		{
+			name:       "pass - failed call",
+			statusCode: http.StatusForbidden,
+			body:       `{"message":"forbidden","statusCode":403}`,
+			wantEvents: []AuditEvent{
+				{SubjectObjectId: "oid", SubjectTenantId: "tid", SubjectApplicationId: "azp", SubjectGroups: []string{"redacted"}, SubjectGroupCount: 1, ResourceId: resourceId, ActionId: "read"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []AuditEvent
+			client, err := NewRemotePDPClientWithOptions(endpoint, scope, test.FakeCredential{}, &ClientOptions{
+				ClientOptions: azcore.ClientOptions{Transport: test.CreateTransport(tt.statusCode, tt.body)},
+				Audit: &AuditOptions{
+					Sinks: []AuditSink{AuditSinkFunc(func(ctx context.Context, event AuditEvent) error {
+						events = append(events, event)
+						return nil
+					})},
+					Redaction: AuditRedaction{
+						IncludeGroups: true,
+						Redact:        func(e *AuditEvent) { e.SubjectGroups[0] = "redacted" },
+					},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Unable to create a new PDP client: %v", err)
+			}
+			testtoken, err := test.CreateTestToken("oid", &internal.Custom{ObjectId: "oid", TenantId: "tid", AuthorizedParty: "azp", Groups: []string{"group1"}})
+			if err != nil {
+				t.Fatalf("Error creating test token: %v", err)
+			}
+			authzReq, err := client.CreateAuthorizationRequest(resourceId, []string{"read"}, testtoken)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+
+			_, checkErr := client.CheckAccess(context.Background(), *authzReq)
+			if (checkErr != nil) != (tt.statusCode != http.StatusOK) {
+				t.Errorf("expected error for status %d but got '%v'", tt.statusCode, checkErr)
+			}
+			if len(events) != len(tt.wantEvents) {
+				t.Fatalf("expected %d events but got %v", len(tt.wantEvents), events)
+			}
+			if checkErr != nil && events[0].Error != checkErr.Error() {
+				t.Errorf("expected the event error to be '%v' but got '%s'", checkErr, events[0].Error)
+			}
+			if diff := cmp.Diff(tt.wantEvents, events, cmpopts.IgnoreFields(AuditEvent{}, "Time", "CorrelationId", "LatencyMs", "Error")); diff != "" {
+				t.Errorf("incorrect audit events: %v", diff)
+			}
+			if authzReq.Subject.Attributes.Groups[0] != "group1" {
+				t.Errorf("expected the redaction not to change the request groups but got %v", authzReq.Subject.Attributes.Groups)
+			}
+		})
+	}
+}
+
+func TestAuditSinks(t *testing.T) {
+	event := AuditEvent{SubjectObjectId: "oid", ResourceId: "/subscriptions/sub", ActionId: "read", AccessDecision: Allowed}
+
+	t.Run("slog", func(t *testing.T) {
+		var buf bytes.Buffer
+		sink := NewSlogAuditSink(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelInfo)
+		if err := sink.WriteAuditEvent(context.Background(), event); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+		if !strings.Contains(buf.String(), `"actionId":"read"`) || !strings.Contains(buf.String(), `"accessDecision":"Allowed"`) {
+			t.Errorf("unexpected log line: %s", buf.String())
+		}
+	})
+
+	t.Run("json lines file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		sink, err := NewJSONLinesFileAuditSink(path)
+		if err != nil {
+			t.Fatalf("Unable to create sink: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := sink.WriteAuditEvent(context.Background(), event); err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines but got %d", len(lines))
+		}
+		var got AuditEvent
+		if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(event, got); diff != "" {
+			t.Errorf("incorrect audit event: %v", diff)
+		}
+	})
+}
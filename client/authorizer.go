@@ -0,0 +1,303 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// authorizerKey is the context key of the Authorizer of a request
+type authorizerKey struct{}
+
+// Authorizer checks the actions of one subject on one resource for the
+// lifetime of a request. Checks are memoized, and actions announced with
+// Prefetch are batched into the next call to the PDP. It is safe for
+// concurrent use: concurrent checks of the same action share one call.
+// A data action and a control action of the same ID are distinct actions.
+type Authorizer struct {
+	client      RemotePDPClient
+	subject     SubjectInfo
+	resource    ResourceInfo
+	environment EnvironmentInfo
+
+	mu        sync.Mutex
+	pending   []ActionInfo
+	inflight  map[string]*authorizerCall
+	decisions map[string]AuthorizationDecision
+	checked   map[string]ActionInfo
+	performed map[string]ActionInfo
+}
+
+// authorizerCall is a call to the PDP in flight, done is closed once err is
+// set and its decisions are memoized
+type authorizerCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewAuthorizer returns the Authorizer of subject on resource
+func NewAuthorizer(client RemotePDPClient, subject SubjectInfo, resource ResourceInfo, environment EnvironmentInfo) (*Authorizer, error) {
+	if client == nil {
+		return nil, fmt.Errorf("need client in creating authorizer")
+	}
+	if resource.Id == "" {
+		return nil, fmt.Errorf("need resource id in creating authorizer")
+	}
+	return &Authorizer{
+		client:      client,
+		subject:     subject,
+		resource:    resource,
+		environment: environment,
+		inflight:    map[string]*authorizerCall{},
+		decisions:   map[string]AuthorizationDecision{},
+		checked:     map[string]ActionInfo{},
+		performed:   map[string]ActionInfo{},
+	}, nil
+}
+
+// WithAuthorizer returns a context carrying the Authorizer a
+func WithAuthorizer(ctx context.Context, a *Authorizer) context.Context {
+	return context.WithValue(ctx, authorizerKey{}, a)
+}
+
+// AuthorizerFromContext returns the Authorizer of ctx, if any
+func AuthorizerFromContext(ctx context.Context) (*Authorizer, bool) {
+	a, ok := ctx.Value(authorizerKey{}).(*Authorizer)
+	return a, ok && a != nil
+}
+
+// Prefetch announces actions the request may check, so they are sent along
+// with the next check instead of in calls of their own. Prefetched actions
+// are reported as checked only once Check is called for them.
+func (a *Authorizer) Prefetch(actions ...ActionInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, actions...)
+}
+
+// Check returns the decisions of actions, calling the PDP only for the ones
+// not checked yet, along with the prefetched ones. The PDP is called without
+// holding the lock, and the actions already in flight are waited for instead
+// of being sent again. Failed calls are not memoized.
+func (a *Authorizer) Check(ctx context.Context, actions ...ActionInfo) (*DecisionResult, error) {
+	for {
+		a.mu.Lock()
+		var missing, prefetched []ActionInfo
+		var waits []*authorizerCall
+		seen := map[string]bool{}
+		for i, action := range append(append([]ActionInfo(nil), actions...), a.pending...) {
+			key := authorizerKeyOf(action)
+			if _, ok := a.decisions[key]; ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if call, ok := a.inflight[key]; ok {
+				if i < len(actions) {
+					waits = append(waits, call)
+				}
+				continue
+			}
+			missing = append(missing, action)
+			if i >= len(actions) {
+				prefetched = append(prefetched, action)
+			}
+		}
+		// the prefetched actions only go with the call of a requested one
+		if len(missing) == len(prefetched) {
+			missing = nil
+		} else {
+			a.pending = nil
+		}
+
+		var call *authorizerCall
+		if len(missing) > 0 {
+			call = &authorizerCall{done: make(chan struct{})}
+			for _, action := range missing {
+				a.inflight[authorizerKeyOf(action)] = call
+			}
+		}
+		a.mu.Unlock()
+
+		if call != nil {
+			if err := a.call(ctx, call, missing, prefetched); err != nil {
+				return nil, err
+			}
+		}
+
+		retry := false
+		for _, wait := range waits {
+			select {
+			case <-wait.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			// the actions of a failed call are checked again by this call
+			retry = retry || wait.err != nil
+		}
+		if !retry {
+			break
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	res := &AuthorizationDecisionResponse{}
+	for _, action := range actions {
+		key := authorizerKeyOf(action)
+		if _, ok := a.checked[key]; !ok {
+			a.checked[key] = action
+		}
+		if decision, ok := a.decisions[key]; ok {
+			res.Value = append(res.Value, decision)
+		}
+	}
+	return NewDecisionResult(actions, res), nil
+}
+
+// call sends actions to the PDP for call and memoizes the decisions. The
+// actions missing from the response are memoized as not allowed. The
+// prefetched actions among them are pending again when the call fails.
+func (a *Authorizer) call(ctx context.Context, call *authorizerCall, actions, prefetched []ActionInfo) error {
+	res, err := a.client.CheckAccess(ctx, AuthorizationRequest{
+		Subject:     a.subject,
+		Actions:     actions,
+		Resource:    a.resource,
+		Environment: a.environment,
+	})
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.pending = append(a.pending, prefetched...)
+	} else {
+		for key, decision := range decisionsOf(actions, res) {
+			a.decisions[key] = decision
+		}
+	}
+	for _, action := range actions {
+		delete(a.inflight, authorizerKeyOf(action))
+	}
+	call.err = err
+	close(call.done)
+	return err
+}
+
+// authorizerKeyOf returns the key of action in the maps of an Authorizer
+func authorizerKeyOf(action ActionInfo) string {
+	if action.IsDataAction {
+		return "data:" + strings.ToLower(action.Id)
+	}
+	return strings.ToLower(action.Id)
+}
+
+// decisionsOf returns the decisions of actions in res by their key, not
+// allowed for the actions missing from res. The decision of a data action
+// is the one of its ID when the PDP leaves out isDataAction.
+func decisionsOf(actions []ActionInfo, res *AuthorizationDecisionResponse) map[string]AuthorizationDecision {
+	byKey := map[string]AuthorizationDecision{}
+	byId := map[string][]AuthorizationDecision{}
+	for _, decision := range res.Value {
+		key := authorizerKeyOf(ActionInfo{Id: decision.ActionId, IsDataAction: decision.IsDataAction})
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = decision
+		}
+		id := strings.ToLower(decision.ActionId)
+		byId[id] = append(byId[id], decision)
+	}
+
+	decisions := map[string]AuthorizationDecision{}
+	for _, action := range actions {
+		key := authorizerKeyOf(action)
+		decision, ok := byKey[key]
+		if ids := byId[strings.ToLower(action.Id)]; !ok && action.IsDataAction && len(ids) == 1 && !ids[0].IsDataAction {
+			decision, ok = ids[0], true
+		}
+		if !ok {
+			decision = AuthorizationDecision{ActionId: action.Id, AccessDecision: NotAllowed, IsDataAction: action.IsDataAction}
+		}
+		decisions[key] = decision
+	}
+	return decisions
+}
+
+// Allowed tells whether action is allowed
+func (a *Authorizer) Allowed(ctx context.Context, action string) (bool, error) {
+	result, err := a.Check(ctx, ActionInfo{Id: action})
+	if err != nil {
+		return false, err
+	}
+	return result.AllAllowed(), nil
+}
+
+// MarkPerformed records that the request performed actions, for Report
+func (a *Authorizer) MarkPerformed(actions ...ActionInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, action := range actions {
+		a.performed[authorizerKeyOf(action)] = action
+	}
+}
+
+// CheckedAction is an action an Authorizer checked
+type CheckedAction struct {
+	ActionId       string
+	IsDataAction   bool
+	AccessDecision AccessDecision
+}
+
+// AuthorizerReport tells which actions a request checked and performed
+type AuthorizerReport struct {
+	// Checked are the checked actions, sorted by ID, control actions first
+	Checked []CheckedAction
+	// Performed are the actions marked performed, sorted
+	Performed []string
+	// Unchecked are the performed actions that were never checked
+	Unchecked []string
+	// NotAllowed are the performed actions that were checked but not allowed
+	NotAllowed []string
+}
+
+// Complete tells whether every performed action was checked and allowed
+func (r AuthorizerReport) Complete() bool {
+	return len(r.Unchecked) == 0 && len(r.NotAllowed) == 0
+}
+
+// Report returns the actions checked and performed so far. Only the actions
+// passed to Check are checked, the prefetched ones are not.
+func (a *Authorizer) Report() AuthorizerReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var report AuthorizerReport
+	for key, action := range a.checked {
+		report.Checked = append(report.Checked, CheckedAction{
+			ActionId:       a.decisions[key].ActionId,
+			IsDataAction:   action.IsDataAction,
+			AccessDecision: a.decisions[key].AccessDecision,
+		})
+	}
+	sort.Slice(report.Checked, func(i, j int) bool {
+		x, y := strings.ToLower(report.Checked[i].ActionId), strings.ToLower(report.Checked[j].ActionId)
+		return x < y || x == y && !report.Checked[i].IsDataAction && report.Checked[j].IsDataAction
+	})
+
+	for key, action := range a.performed {
+		report.Performed = append(report.Performed, action.Id)
+		_, checked := a.checked[key]
+		switch {
+		case !checked:
+			report.Unchecked = append(report.Unchecked, action.Id)
+		case a.decisions[key].AccessDecision != Allowed:
+			report.NotAllowed = append(report.NotAllowed, action.Id)
+		}
+	}
+	sort.Strings(report.Performed)
+	sort.Strings(report.Unchecked)
+	sort.Strings(report.NotAllowed)
+	return report
+}
@@ -0,0 +1,234 @@
+package client_test
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/fakepdp"
+)
+
+func TestAuthorizer(t *testing.T) {
+	var batches [][]string
+	pdp := &fakepdp.Client{CheckAccessFunc: func(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		var batch []string
+		res := &client.AuthorizationDecisionResponse{}
+		for _, action := range authzReq.Actions {
+			batch = append(batch, action.Id)
+			decision := client.Allowed
+			if action.Id == "delete" {
+				decision = client.NotAllowed
+			}
+			res.Value = append(res.Value, client.AuthorizationDecision{ActionId: action.Id, AccessDecision: decision})
+		}
+		batches = append(batches, batch)
+		return res, nil
+	}}
+	a, err := client.NewAuthorizer(pdp, client.SubjectInfo{Attributes: client.SubjectAttributes{ObjectId: "oid"}}, client.ResourceInfo{Id: "/subscriptions/sub"}, client.EnvironmentInfo{})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	ctx := client.WithAuthorizer(context.Background(), a)
+
+	fromCtx, ok := client.AuthorizerFromContext(ctx)
+	if !ok || fromCtx != a {
+		t.Fatal("expected the authorizer in the context")
+	}
+	if _, ok := client.AuthorizerFromContext(context.Background()); ok {
+		t.Error("expected no authorizer in an empty context")
+	}
+
+	fromCtx.Prefetch(client.ActionInfo{Id: "write"}, client.ActionInfo{Id: "delete"})
+	if allowed, err := fromCtx.Allowed(ctx, "read"); err != nil || !allowed {
+		t.Errorf("expected read to be allowed but got %v '%v'", allowed, err)
+	}
+	// write and delete were prefetched with read
+	result, err := fromCtx.Check(ctx, client.ActionInfo{Id: "WRITE"}, client.ActionInfo{Id: "delete"})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if diff := cmp.Diff([]string{"delete"}, result.NotAllowedActions()); diff != "" {
+		t.Errorf("incorrect not allowed actions: %v", diff)
+	}
+	if diff := cmp.Diff([][]string{{"read", "write", "delete"}}, batches); diff != "" {
+		t.Errorf("incorrect batches: %v", diff)
+	}
+
+	a.MarkPerformed(client.ActionInfo{Id: "read"}, client.ActionInfo{Id: "delete"}, client.ActionInfo{Id: "action"})
+	want := client.AuthorizerReport{
+		Checked: []client.CheckedAction{
+			{ActionId: "delete", AccessDecision: client.NotAllowed},
+			{ActionId: "read", AccessDecision: client.Allowed},
+			{ActionId: "write", AccessDecision: client.Allowed},
+		},
+		Performed:  []string{"action", "delete", "read"},
+		Unchecked:  []string{"action"},
+		NotAllowed: []string{"delete"},
+	}
+	report := a.Report()
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("incorrect report: %v", diff)
+	}
+	if report.Complete() {
+		t.Error("expected the report not to be complete")
+	}
+}
+
+func TestAuthorizerErrorsAreNotMemoized(t *testing.T) {
+	fail := true
+	pdp := &fakepdp.Client{CheckAccessFunc: func(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		if fail {
+			return nil, errors.New("unavailable")
+		}
+		return fakepdp.Respond(authzReq, nil, client.Allowed), nil
+	}}
+	a, err := client.NewAuthorizer(pdp, client.SubjectInfo{}, client.ResourceInfo{Id: "/subscriptions/sub"}, client.EnvironmentInfo{})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	if _, err := a.Allowed(context.Background(), "read"); err == nil {
+		t.Error("expected an error")
+	}
+	fail = false
+	if allowed, err := a.Allowed(context.Background(), "read"); err != nil || !allowed {
+		t.Errorf("expected read to be allowed but got %v '%v'", allowed, err)
+	}
+	if pdp.Calls() != 2 {
+		t.Errorf("expected 2 calls but got %d", pdp.Calls())
+	}
+	a.MarkPerformed(client.ActionInfo{Id: "read"})
+	if !a.Report().Complete() {
+		t.Error("expected the report to be complete")
+	}
+}
+
+func TestAuthorizerReportsOnlyCheckedActions(t *testing.T) {
+	a, err := client.NewAuthorizer(fakepdp.New(client.Allowed), client.SubjectInfo{}, client.ResourceInfo{Id: "/subscriptions/sub"}, client.EnvironmentInfo{})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	a.Prefetch(client.ActionInfo{Id: "write"})
+	if allowed, err := a.Allowed(context.Background(), "read"); err != nil || !allowed {
+		t.Errorf("expected read to be allowed but got %v '%v'", allowed, err)
+	}
+	a.MarkPerformed(client.ActionInfo{Id: "write"})
+	want := client.AuthorizerReport{
+		Checked:   []client.CheckedAction{{ActionId: "read", AccessDecision: client.Allowed}},
+		Performed: []string{"write"},
+		Unchecked: []string{"write"},
+	}
+	if diff := cmp.Diff(want, a.Report()); diff != "" {
+		t.Errorf("incorrect report: %v", diff)
+	}
+}
+
+func TestAuthorizerDataActions(t *testing.T) {
+	pdp := &fakepdp.Client{CheckAccessFunc: func(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		res := &client.AuthorizationDecisionResponse{}
+		for _, action := range authzReq.Actions {
+			// the PDP leaves out the decision of the blob action
+			if action.Id == "blobs/read" {
+				continue
+			}
+			decision := client.NotAllowed
+			if action.IsDataAction {
+				decision = client.Allowed
+			}
+			res.Value = append(res.Value, client.AuthorizationDecision{ActionId: action.Id, AccessDecision: decision, IsDataAction: action.IsDataAction})
+		}
+		return res, nil
+	}}
+	a, err := client.NewAuthorizer(pdp, client.SubjectInfo{}, client.ResourceInfo{Id: "/subscriptions/sub"}, client.EnvironmentInfo{})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if result, err := a.Check(context.Background(), client.ActionInfo{Id: "read", IsDataAction: true}); err != nil || !result.AllAllowed() {
+			t.Errorf("expected the data action read to be allowed but got '%v'", err)
+		}
+		if allowed, err := a.Allowed(context.Background(), "read"); err != nil || allowed {
+			t.Errorf("expected the control action read not to be allowed but got %v '%v'", allowed, err)
+		}
+		if allowed, err := a.Allowed(context.Background(), "blobs/read"); err != nil || allowed {
+			t.Errorf("expected blobs/read not to be allowed but got %v '%v'", allowed, err)
+		}
+	}
+	// the data and control actions are checked once each, the missing
+	// decision is memoized
+	if pdp.Calls() != 3 {
+		t.Errorf("expected 3 calls but got %d", pdp.Calls())
+	}
+
+	a.MarkPerformed(client.ActionInfo{Id: "read", IsDataAction: true}, client.ActionInfo{Id: "blobs/read"})
+	want := client.AuthorizerReport{
+		Checked: []client.CheckedAction{
+			{ActionId: "blobs/read", AccessDecision: client.NotAllowed},
+			{ActionId: "read", AccessDecision: client.NotAllowed},
+			{ActionId: "read", IsDataAction: true, AccessDecision: client.Allowed},
+		},
+		Performed:  []string{"blobs/read", "read"},
+		NotAllowed: []string{"blobs/read"},
+	}
+	if diff := cmp.Diff(want, a.Report()); diff != "" {
+		t.Errorf("incorrect report: %v", diff)
+	}
+}
+
+func TestAuthorizerConcurrentChecks(t *testing.T) {
+	release := make(chan struct{})
+	pdp := &fakepdp.Client{CheckAccessFunc: func(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		if authzReq.Actions[0].Id == "read" {
+			<-release
+		}
+		return fakepdp.Respond(authzReq, nil, client.Allowed), nil
+	}}
+	a, err := client.NewAuthorizer(pdp, client.SubjectInfo{}, client.ResourceInfo{Id: "/subscriptions/sub"}, client.EnvironmentInfo{})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if allowed, err := a.Allowed(context.Background(), "read"); err != nil || !allowed {
+				t.Errorf("expected read to be allowed but got %v '%v'", allowed, err)
+			}
+		}()
+	}
+	for pdp.Calls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the call of read is blocked, the check of write doesn't wait for it
+	if allowed, err := a.Allowed(context.Background(), "write"); err != nil || !allowed {
+		t.Errorf("expected write to be allowed but got %v '%v'", allowed, err)
+	}
+	close(release)
+	wg.Wait()
+
+	if pdp.Calls() != 2 {
+		t.Errorf("expected 2 calls but got %d", pdp.Calls())
+	}
+}
+
+func TestNewAuthorizer(t *testing.T) {
+	if _, err := client.NewAuthorizer(nil, client.SubjectInfo{}, client.ResourceInfo{Id: "id"}, client.EnvironmentInfo{}); err == nil || err.Error() != "need client in creating authorizer" {
+		t.Errorf("expected error to be 'need client in creating authorizer' but got '%v'", err)
+	}
+	if _, err := client.NewAuthorizer(fakepdp.New(client.Allowed), client.SubjectInfo{}, client.ResourceInfo{}, client.EnvironmentInfo{}); err == nil || err.Error() != "need resource id in creating authorizer" {
+		t.Errorf("expected error to be 'need resource id in creating authorizer' but got '%v'", err)
+	}
+}
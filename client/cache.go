@@ -0,0 +1,133 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DecisionCache stores the responses of CheckAccess calls. Implementations
+// must be safe for concurrent use.
+type DecisionCache interface {
+	// Get returns the response stored for key, if it has not expired
+	Get(key string) (*AuthorizationDecisionResponse, bool)
+	// Set stores res for key during ttl
+	Set(key string, res *AuthorizationDecisionResponse, ttl time.Duration)
+}
+
+// memoryDecisionCache is an in-memory LRU DecisionCache
+type memoryDecisionCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+type cacheEntry struct {
+	key       string
+	res       *AuthorizationDecisionResponse
+	expiresAt time.Time
+}
+
+// NewMemoryDecisionCache returns an in-memory DecisionCache evicting the
+// least recently used entry beyond maxEntries
+func NewMemoryDecisionCache(maxEntries int) *memoryDecisionCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &memoryDecisionCache{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (c *memoryDecisionCache) Get(key string) (*AuthorizationDecisionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.res, true
+}
+
+func (c *memoryDecisionCache) Set(key string, res *AuthorizationDecisionResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, res: res, expiresAt: c.now().Add(ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of entries in the cache, expired or not
+func (c *memoryDecisionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// decisionCacheKey returns the cache key of authzReq sent to endpoint
+func decisionCacheKey(endpoint string, authzReq AuthorizationRequest) (string, error) {
+	payload, err := json.Marshal(authzReq)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	sum.Write([]byte(endpoint))
+	sum.Write([]byte{0})
+	sum.Write(payload)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// decisionTTL returns how long res can be cached: the shortest time to live
+// of its decisions, zero when a decision has none
+func decisionTTL(res *AuthorizationDecisionResponse) time.Duration {
+	if res == nil || len(res.Value) == 0 || res.NextLink != "" {
+		return 0
+	}
+	var ttl time.Duration
+	for i, decision := range res.Value {
+		d := time.Duration(decision.TimeToLiveInMs) * time.Millisecond
+		if d <= 0 {
+			return 0
+		}
+		if i == 0 || d < ttl {
+			ttl = d
+		}
+	}
+	return ttl
+}
+
+// copyResponse returns a copy of res whose decisions can be modified
+func copyResponse(res *AuthorizationDecisionResponse) *AuthorizationDecisionResponse {
+	c := *res
+	c.Value = append([]AuthorizationDecision(nil), res.Value...)
+	return &c
+}
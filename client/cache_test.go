@@ -0,0 +1,70 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryDecisionCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewMemoryDecisionCache(2)
+	cache.now = func() time.Time { return now }
+
+	res := &AuthorizationDecisionResponse{Value: []AuthorizationDecision{{ActionId: "read", AccessDecision: Allowed}}}
+	cache.Set("a", res, time.Minute)
+	cache.Set("b", res, time.Hour)
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("expected a to be cached")
+	}
+	// b is the least recently used entry
+	cache.Set("c", res, time.Hour)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected a to be expired")
+	}
+	if _, ok := cache.Get("c"); !ok {
+		t.Error("expected c to be cached")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 entry but got %d", cache.Len())
+	}
+}
+
+func TestDecisionTTL(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		res  *AuthorizationDecisionResponse
+		want time.Duration
+	}{
+		{
+			name: "pass - shortest time to live",
+			res:  &AuthorizationDecisionResponse{Value: []AuthorizationDecision{{TimeToLiveInMs: 3000}, {TimeToLiveInMs: 1000}}},
+			want: time.Second,
+		},
+		{
+			name: "pass - a decision without time to live is not cached",
+			res:  &AuthorizationDecisionResponse{Value: []AuthorizationDecision{{TimeToLiveInMs: 3000}, {}}},
+		},
+		{
+			name: "pass - a paginated response is not cached",
+			res:  &AuthorizationDecisionResponse{Value: []AuthorizationDecision{{TimeToLiveInMs: 3000}}, NextLink: "next"},
+		},
+		{
+			name: "pass - an empty response is not cached",
+			res:  &AuthorizationDecisionResponse{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decisionTTL(tt.res); got != tt.want {
+				t.Errorf("expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
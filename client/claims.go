@@ -0,0 +1,117 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal"
+)
+
+// Claims are the claims of an already validated token the SubjectAttributes
+// are derived from
+type Claims struct {
+	ObjectId         string                 `json:"oid,omitempty"`
+	TenantId         string                 `json:"tid,omitempty"`
+	ApplicationId    string                 `json:"appid,omitempty"`
+	AuthorizedParty  string                 `json:"azp,omitempty"`
+	Issuer           string                 `json:"iss,omitempty"`
+	IdentityProvider string                 `json:"idp,omitempty"`
+	AltSecId         string                 `json:"altsecid,omitempty"`
+	Groups           []string               `json:"groups,omitempty"`
+	ClaimNames       map[string]interface{} `json:"_claim_names,omitempty"`
+
+	// Raw are all the claims when the Claims come from a map, for mappers
+	// of identity providers with custom claims
+	Raw map[string]interface{} `json:"-"`
+}
+
+// ClaimsFromMap returns the Claims of a claims map, e.g. the MapClaims of a
+// validated JWT
+func ClaimsFromMap(claims map[string]interface{}) (*Claims, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("error while marshaling the claims, err: %w", err)
+	}
+	c := &Claims{}
+	if err := json.Unmarshal(payload, c); err != nil {
+		return nil, fmt.Errorf("error while parsing the claims, err: %w", err)
+	}
+	c.Raw = claims
+	return c, nil
+}
+
+// ClaimsMapper derives the SubjectAttributes of the subject of claims
+type ClaimsMapper func(claims *Claims) (SubjectAttributes, error)
+
+// DefaultClaimsMapper is the ClaimsMapper of Entra ID tokens, the one
+// CreateAuthorizationRequest uses: the object and tenant IDs, the
+// application ID of the appid claim, or azp when absent, and either the
+// groups or a hint to expand them when the token only has a group overage
+// claim.
+func DefaultClaimsMapper(claims *Claims) (SubjectAttributes, error) {
+	subjectAttributes := SubjectAttributes{}
+	subjectAttributes.ObjectId = claims.ObjectId
+	subjectAttributes.TenantId = claims.TenantId
+	subjectAttributes.ApplicationId = claims.ApplicationId
+	if subjectAttributes.ApplicationId == "" {
+		subjectAttributes.ApplicationId = claims.AuthorizedParty
+	}
+
+	if claims.ClaimNames != nil && len(claims.Groups) == 0 {
+		subjectAttributes.ClaimName = GroupExpansion
+	} else if claims.ClaimNames == nil && len(claims.Groups) > 0 {
+		subjectAttributes.Groups = claims.Groups
+	}
+	return subjectAttributes, nil
+}
+
+// ClaimsSubject returns the SubjectSource of the subject of claims, mapped
+// by mapper, DefaultClaimsMapper when nil
+func ClaimsSubject(claims *Claims, mapper ClaimsMapper) SubjectSource {
+	if mapper == nil {
+		mapper = DefaultClaimsMapper
+	}
+	return SubjectSourceFunc(func(ctx context.Context) (SubjectAttributes, error) {
+		if claims == nil {
+			return SubjectAttributes{}, fmt.Errorf("need claims in creating SubjectAttributes")
+		}
+		return mapper(claims)
+	})
+}
+
+// ClaimsMapSubject is ClaimsSubject for a claims map
+func ClaimsMapSubject(claims map[string]interface{}, mapper ClaimsMapper) SubjectSource {
+	return SubjectSourceFunc(func(ctx context.Context) (SubjectAttributes, error) {
+		c, err := ClaimsFromMap(claims)
+		if err != nil {
+			return SubjectAttributes{}, err
+		}
+		return ClaimsSubject(c, mapper).Subject(ctx)
+	})
+}
+
+// NewAuthorizationRequestFromClaims creates the AuthorizationRequest of the
+// subject of claims without parsing a JWT again. mapper is
+// DefaultClaimsMapper when nil.
+func NewAuthorizationRequestFromClaims(resourceId string, actions []string, claims *Claims, mapper ClaimsMapper) (*AuthorizationRequest, error) {
+	return NewAuthorizationRequest(context.Background(), resourceId, actions, ClaimsSubject(claims, mapper))
+}
+
+// claimsFromCustom returns the Claims of the parsed claims of a token
+func claimsFromCustom(c *internal.Custom) *Claims {
+	return &Claims{
+		ObjectId:         c.ObjectId,
+		TenantId:         c.TenantId,
+		ApplicationId:    c.AppId,
+		AuthorizedParty:  c.AuthorizedParty,
+		Issuer:           c.Issuer,
+		IdentityProvider: c.IdentityProvider,
+		AltSecId:         c.AltSecId,
+		Groups:           c.Groups,
+		ClaimNames:       c.ClaimNames,
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClaimsSubject(t *testing.T) {
+	overage := map[string]interface{}{"groups": "src1"}
+
+	for _, tt := range []struct {
+		name    string
+		source  SubjectSource
+		want    SubjectAttributes
+		wantErr string
+	}{
+		{
+			name:   "pass - groups from a struct",
+			source: ClaimsSubject(&Claims{ObjectId: "oid", Groups: []string{"group1"}}, nil),
+			want:   SubjectAttributes{ObjectId: "oid", Groups: []string{"group1"}},
+		},
+		{
+			name:   "pass - group overage from a map",
+			source: ClaimsMapSubject(map[string]interface{}{"oid": "oid", "_claim_names": overage}, nil),
+			want:   SubjectAttributes{ObjectId: "oid", ClaimName: GroupExpansion},
+		},
+		{
+			name:   "pass - neither groups nor group expansion when both exist",
+			source: ClaimsMapSubject(map[string]interface{}{"oid": "oid", "groups": []interface{}{"group1"}, "_claim_names": overage}, nil),
+			want:   SubjectAttributes{ObjectId: "oid"},
+		},
+		{
+			name: "pass - custom mapper reads raw claims",
+			source: ClaimsMapSubject(map[string]interface{}{"sub": "custom-subject", "tenant": "tid"}, func(c *Claims) (SubjectAttributes, error) {
+				return SubjectAttributes{ObjectId: c.Raw["sub"].(string), TenantId: c.Raw["tenant"].(string)}, nil
+			}),
+			want: SubjectAttributes{ObjectId: "custom-subject", TenantId: "tid"},
+		},
+		{
+			name: "fail - custom mapper error",
+			source: ClaimsSubject(&Claims{}, func(c *Claims) (SubjectAttributes, error) {
+				return SubjectAttributes{}, errors.New("no subject")
+			}),
+			wantErr: "no subject",
+		},
+		{
+			name:    "fail - nil claims",
+			source:  ClaimsSubject(nil, nil),
+			wantErr: "need claims in creating SubjectAttributes",
+		},
+		{
+			name:    "fail - groups of the wrong type",
+			source:  ClaimsMapSubject(map[string]interface{}{"groups": "group1"}, nil),
+			wantErr: "error while parsing the claims",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.source.Subject(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error to start with '%s' but got '%v'", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("incorrect subject: %v", diff)
+			}
+		})
+	}
+}
+
+func TestNewAuthorizationRequestFromClaims(t *testing.T) {
+	got, err := NewAuthorizationRequestFromClaims("/subscriptions/sub", []string{"read"}, &Claims{ObjectId: "oid"}, nil)
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	want := &AuthorizationRequest{
+		Subject:  SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid"}},
+		Actions:  []ActionInfo{{Id: "read"}},
+		Resource: ResourceInfo{Id: "/subscriptions/sub"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("incorrect AuthorizationRequest: %v", diff)
+	}
+}
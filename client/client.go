@@ -9,11 +9,17 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal"
 	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/token"
 )
 
@@ -28,8 +34,38 @@ type RemotePDPClient interface {
 
 // remotePDPClient implements RemotePDPClient
 type remotePDPClient struct {
-	endpoint string
-	pipeline runtime.Pipeline
+	endpoint  string
+	pipeline  runtime.Pipeline
+	telemetry *telemetry
+	auditor   *auditor
+	cache     DecisionCache
+	resolver  *ResourceAttributeOptions
+}
+
+// ClientOptions contains the optional settings of a remotePDPClient
+type ClientOptions struct {
+	azcore.ClientOptions
+
+	// TracerProvider creates the tracer of the client's spans.
+	// The global TracerProvider is used when nil.
+	TracerProvider trace.TracerProvider
+
+	// MeterProvider creates the meter of the client's metrics.
+	// The global MeterProvider is used when nil.
+	MeterProvider metric.MeterProvider
+
+	// Audit configures the audit events emitted for every decision.
+	// No events are emitted when nil.
+	Audit *AuditOptions
+
+	// DecisionCache, if set, stores the responses of CheckAccess for the
+	// shortest timeToLiveInMs of their decisions. Responses with a decision
+	// without time to live, or with a NextLink, are not cached.
+	DecisionCache DecisionCache
+
+	// ResourceAttributes, if set, resolves the attributes of the resource of
+	// every request before it is sent, e.g. its tags for ABAC conditions.
+	ResourceAttributes *ResourceAttributeOptions
 }
 
 // NewRemotePDPClient returns an implementation of RemotePDPClient
@@ -38,6 +74,19 @@ type remotePDPClient struct {
 // cred - the credential of the client to call the PDP server
 // ClientOptions - the optional settings for a client's pipeline.
 func NewRemotePDPClient(endpoint, scope string, cred azcore.TokenCredential, clientOptions *azcore.ClientOptions) (*remotePDPClient, error) {
+	options := &ClientOptions{}
+	if clientOptions != nil {
+		options.ClientOptions = *clientOptions
+	}
+	return NewRemotePDPClientWithOptions(endpoint, scope, cred, options)
+}
+
+// NewRemotePDPClientWithOptions returns an implementation of RemotePDPClient
+// endpoint - the fqdn of the regional specific endpoint of PDP
+// scope - the oauth scope required by the PDP server
+// cred - the credential of the client to call the PDP server
+// options - the optional settings for the client, nil uses the defaults.
+func NewRemotePDPClientWithOptions(endpoint, scope string, cred azcore.TokenCredential, options *ClientOptions) (*remotePDPClient, error) {
 	if strings.TrimSpace(endpoint) == "" {
 		return nil, fmt.Errorf("endpoint: %s is not valid, need a valid endpoint in creating client", endpoint)
 	}
@@ -48,69 +97,142 @@ func NewRemotePDPClient(endpoint, scope string, cred azcore.TokenCredential, cli
 		return nil, fmt.Errorf("need TokenCredential in creating client")
 	}
 
+	if options == nil {
+		options = &ClientOptions{}
+	}
+
 	authPolicy := runtime.NewBearerTokenPolicy(cred, []string{scope}, nil)
 
 	pipeline := runtime.NewPipeline(
 		modulename,
 		version,
 		runtime.PipelineOptions{
-			PerCall:  []policy.Policy{},
-			PerRetry: []policy.Policy{authPolicy},
+			PerCall:  []policy.Policy{runtime.NewRequestIDPolicy()},
+			PerRetry: []policy.Policy{attemptCounterPolicy{}, authPolicy},
 		},
-		clientOptions,
+		&options.ClientOptions,
 	)
 
-	return &remotePDPClient{endpoint, pipeline}, nil
+	telemetry, err := newTelemetry(endpoint, options.TracerProvider, options.MeterProvider)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating telemetry instruments, err: %w", err)
+	}
+
+	return &remotePDPClient{
+		endpoint:  endpoint,
+		pipeline:  pipeline,
+		telemetry: telemetry,
+		auditor:   newAuditor(options.Audit),
+		cache:     options.DecisionCache,
+		resolver:  options.ResourceAttributes,
+	}, nil
 }
 
 // CheckAccess sends an Authorization query to the PDP server specified in the client
 // ctx - the context to propagate
 // authzReq - the actual AuthorizationRequest
-func (r *remotePDPClient) CheckAccess(ctx context.Context, authzReq AuthorizationRequest) (*AuthorizationDecisionResponse, error) {
+func (r *remotePDPClient) CheckAccess(ctx context.Context, authzReq AuthorizationRequest) (res *AuthorizationDecisionResponse, err error) {
+	ctx, span := r.telemetry.startCheckAccess(ctx, authzReq)
+	ctx, attempts := withAttemptCounter(ctx)
+	start := time.Now()
+	var raw *http.Response
+	var cacheHit bool
+	defer func() {
+		tries := atomic.LoadInt32(attempts)
+		r.telemetry.endCheckAccess(ctx, span, start, tries, cacheHit, res, err)
+		r.auditor.record(ctx, authzReq, res, err, raw, time.Since(start), tries > 1)
+	}()
+
+	if authzReq, err = r.resolveResourceAttributes(ctx, authzReq); err != nil {
+		return nil, err
+	}
+	if res, cacheHit = r.cachedDecision(authzReq); cacheHit {
+		return res, nil
+	}
+
+	res, raw, err = r.checkAccess(ctx, authzReq)
+	if err == nil && r.cache != nil {
+		if ttl := decisionTTL(res); ttl > 0 {
+			if key, keyErr := decisionCacheKey(r.endpoint, authzReq); keyErr == nil {
+				r.cache.Set(key, copyResponse(res), ttl)
+			}
+		}
+	}
+	return res, err
+}
+
+// cachedDecision returns a copy of the cached response of authzReq, if any
+func (r *remotePDPClient) cachedDecision(authzReq AuthorizationRequest) (*AuthorizationDecisionResponse, bool) {
+	if r.cache == nil {
+		return nil, false
+	}
+	key, err := decisionCacheKey(r.endpoint, authzReq)
+	if err != nil {
+		return nil, false
+	}
+	cached, ok := r.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return copyResponse(cached), true
+}
+
+// checkAccess sends authzReq to the PDP server and decodes its decisions.
+// The raw response is returned along with the decisions when available.
+func (r *remotePDPClient) checkAccess(ctx context.Context, authzReq AuthorizationRequest) (*AuthorizationDecisionResponse, *http.Response, error) {
 	req, err := runtime.NewRequest(ctx, http.MethodPost, r.endpoint)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := runtime.MarshalAsJSON(req, authzReq); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	res, err := r.pipeline.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if res.StatusCode != http.StatusOK {
-		return nil, newCheckAccessError(res)
+		return nil, res, newCheckAccessError(res)
 	}
 
 	var accessDecision AuthorizationDecisionResponse
 	if err := runtime.UnmarshalAsJSON(res, &accessDecision); err != nil {
-		return nil, err
+		return nil, res, err
 	}
 
-	return &accessDecision, nil
+	return &accessDecision, res, nil
 }
 
 // newCheckAccessError returns an error when non HTTP 200 response is returned.
+// Bodies that aren't a CheckAccessErrorResponse, e.g. from a proxy, still
+// yield a ResponseError, without an ErrorCode.
 func newCheckAccessError(r *http.Response) error {
 	payload, err := runtime.Payload(r)
 	if err != nil {
 		return err
 	}
-	var checkAccessError CheckAccessErrorResponse
-	err = json.Unmarshal(payload, &checkAccessError)
-	if err != nil {
-		return err
-	}
-	return &azcore.ResponseError{
+	responseErr := &azcore.ResponseError{
 		StatusCode:  r.StatusCode,
 		RawResponse: r,
-		ErrorCode:   fmt.Sprint(checkAccessError.StatusCode),
 	}
+	var checkAccessError CheckAccessErrorResponse
+	if err := json.Unmarshal(payload, &checkAccessError); err == nil {
+		responseErr.ErrorCode = fmt.Sprint(checkAccessError.StatusCode)
+	}
+	return responseErr
 }
 
 // CreateAuthorizationRequest creates an AuthorizationRequest object
-func (r *remotePDPClient) CreateAuthorizationRequest(resourceId string, actions []string, jwtToken string) (*AuthorizationRequest, error) {
+func (r *remotePDPClient) CreateAuthorizationRequest(resourceId string, actions []string, jwtToken string) (authzReq *AuthorizationRequest, err error) {
+	span := r.telemetry.startCreateAuthorizationRequest(len(actions))
+	defer func() { endSpan(span, err) }()
+
+	return createAuthorizationRequest(resourceId, actions, jwtToken)
+}
+
+// createAuthorizationRequest builds an AuthorizationRequest from the claims of jwtToken
+func createAuthorizationRequest(resourceId string, actions []string, jwtToken string) (*AuthorizationRequest, error) {
 	if strings.TrimSpace(jwtToken) == "" {
 		return nil, fmt.Errorf("need token in creating AuthorizationRequest")
 	}
@@ -120,15 +242,12 @@ func (r *remotePDPClient) CreateAuthorizationRequest(resourceId string, actions
 		return nil, fmt.Errorf("error while parse the token, err: %w", err)
 	}
 
-	subjectAttributes := SubjectAttributes{}
-	subjectAttributes.ObjectId = tokenClaims.ObjectId
-
-	if tokenClaims.ClaimNames != nil && len(tokenClaims.Groups) == 0 {
-		subjectAttributes.ClaimName = GroupExpansion
-	} else if tokenClaims.ClaimNames == nil && len(tokenClaims.Groups) > 0 {
-		subjectAttributes.Groups = tokenClaims.Groups
-	}
+	return newAuthorizationRequest(resourceId, actions, subjectAttributesFromClaims(tokenClaims)), nil
+}
 
+// newAuthorizationRequest builds the AuthorizationRequest of subjectAttributes
+// for actions on resourceId
+func newAuthorizationRequest(resourceId string, actions []string, subjectAttributes SubjectAttributes) *AuthorizationRequest {
 	actionInfos := []ActionInfo{}
 	for _, action := range actions {
 		actionInfos = append(actionInfos, ActionInfo{Id: action})
@@ -142,5 +261,12 @@ func (r *remotePDPClient) CreateAuthorizationRequest(resourceId string, actions
 		Resource: ResourceInfo{
 			Id: resourceId,
 		},
-	}, nil
+	}
+}
+
+// subjectAttributesFromClaims returns the SubjectAttributes of the subject
+// of a token, as DefaultClaimsMapper derives them.
+func subjectAttributesFromClaims(tokenClaims *internal.Custom) SubjectAttributes {
+	subjectAttributes, _ := DefaultClaimsMapper(claimsFromCustom(tokenClaims))
+	return subjectAttributes
 }
@@ -16,6 +16,7 @@ import (
 
 	"github.com/Azure/checkaccess-v2-go-sdk/client/internal"
 	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/recording"
 )
 
 func TestClientCreate(t *testing.T) {
@@ -97,7 +98,11 @@ func TestCheckAccess(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.desc, func(t *testing.T) {
 			mockPipeline := test.CreatePipelineWithServer(tt.returnedHttpCode)
-			client := &remotePDPClient{endpoint, mockPipeline}
+			telemetry, err := newTelemetry(endpoint, nil, nil)
+			if err != nil {
+				t.Fatalf("Unable to create telemetry: %v", err)
+			}
+			client := &remotePDPClient{endpoint: endpoint, pipeline: mockPipeline, telemetry: telemetry}
 			decision, err := client.CheckAccess(context.Background(), AuthorizationRequest{})
 			if decision != tt.expectedDecision && !errors.Is(err, tt.expectedErr) {
 				t.Errorf("expected decision to be %v; and error to be %s. Got %v and %s",
@@ -226,3 +231,66 @@ func TestCreateAuthorizationRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckAccessReplay(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	scope := "https://authorization.azure.net/.default"
+	subscription := "/subscriptions/00000000-0000-0000-0000-000000000000"
+	vm := subscription + "/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm"
+
+	transport, err := recording.NewTransport(recording.Options{Mode: recording.ModeReplay, FixturePath: "testdata/checkaccess.json"})
+	if err != nil {
+		t.Fatalf("Unable to create replay transport: %v", err)
+	}
+	client, err := NewRemotePDPClient(endpoint, scope, test.FakeCredential{}, &azcore.ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+
+	subject := SubjectInfo{Attributes: SubjectAttributes{ObjectId: "1234567890"}}
+	decision, err := client.CheckAccess(context.Background(), AuthorizationRequest{
+		Subject:  subject,
+		Actions:  []ActionInfo{{Id: "Microsoft.Compute/virtualMachines/read"}, {Id: "Microsoft.Compute/virtualMachines/delete"}},
+		Resource: ResourceInfo{Id: vm},
+	})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	want := &AuthorizationDecisionResponse{Value: []AuthorizationDecision{
+		{
+			ActionId:       "Microsoft.Compute/virtualMachines/read",
+			AccessDecision: Allowed,
+			RoleAssignment: RoleAssignment{
+				Id:               "ra1",
+				RoleDefinitionId: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+				PrincipalId:      "REDACTED:87d15aeb1a1962cf7e62c0b84fb15282",
+				PrincipalType:    "User",
+				Scope:            subscription + "/resourceGroups/rg",
+			},
+			TimeToLiveInMs: 300000,
+		},
+		{
+			ActionId:       "Microsoft.Compute/virtualMachines/delete",
+			AccessDecision: Denied,
+			DenyAssignment: RoleDefinition{Id: "da1"},
+			TimeToLiveInMs: 300000,
+		},
+	}}
+	if diff := cmp.Diff(want, decision); diff != "" {
+		t.Errorf("incorrect decision: %v", diff)
+	}
+
+	_, err = client.CheckAccess(context.Background(), AuthorizationRequest{
+		Subject:  subject,
+		Actions:  []ActionInfo{{Id: "Microsoft.Compute/virtualMachines/read"}},
+		Resource: ResourceInfo{Id: subscription},
+	})
+	var responseErr *azcore.ResponseError
+	if !errors.As(err, &responseErr) || responseErr.StatusCode != http.StatusForbidden || responseErr.ErrorCode != "403" {
+		t.Errorf("expected a 403 ResponseError but got '%v'", err)
+	}
+
+	if unused := transport.Unused(); len(unused) != 0 {
+		t.Errorf("expected every interaction to be replayed but got %v", unused)
+	}
+}
@@ -0,0 +1,238 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// this asserts that &comparingClient{} would always implement RemotePDPClient
+var _ RemotePDPClient = &comparingClient{}
+
+// MismatchKind tells how the decisions of two clients for an action differ
+type MismatchKind string
+
+// MismatchKind possible values
+const (
+	MismatchAccessDecision MismatchKind = "AccessDecision"
+	MismatchRoleAssignment MismatchKind = "RoleAssignment"
+	MismatchDenyAssignment MismatchKind = "DenyAssignment"
+	MismatchMissing        MismatchKind = "Missing"
+	MismatchError          MismatchKind = "Error"
+)
+
+// ActionMismatch is a divergence between the primary and secondary decisions
+// of an action. Primary or Secondary is nil when that client returned no
+// decision for the action.
+type ActionMismatch struct {
+	ActionId  string
+	Kind      MismatchKind
+	Primary   *AuthorizationDecision
+	Secondary *AuthorizationDecision
+}
+
+// Comparison holds both sides of a compared CheckAccess call
+type Comparison struct {
+	Request           AuthorizationRequest
+	PrimaryResponse   *AuthorizationDecisionResponse
+	PrimaryErr        error
+	SecondaryResponse *AuthorizationDecisionResponse
+	SecondaryErr      error
+	Mismatches        []ActionMismatch
+}
+
+// ComparisonOptions configures a comparingClient
+type ComparisonOptions struct {
+	// SampleRate is the fraction of calls, between 0 and 1, also sent to the
+	// secondary client
+	SampleRate float64
+
+	// Timeout bounds the secondary call, which runs in the background and
+	// outlives the caller's context. No timeout is applied when zero.
+	Timeout time.Duration
+
+	// OnMismatch is called with every comparison that diverged
+	OnMismatch func(context.Context, Comparison)
+
+	// MeterProvider creates the meter of the comparison metrics.
+	// The global MeterProvider is used when nil.
+	MeterProvider metric.MeterProvider
+
+	// Rand returns a number in [0, 1) used for sampling, rand.Float64 when nil
+	Rand func() float64
+}
+
+// comparingClient returns the decisions of a primary client and compares
+// them in the background with the decisions of a secondary client
+type comparingClient struct {
+	primary     RemotePDPClient
+	secondary   RemotePDPClient
+	options     ComparisonOptions
+	comparisons metric.Int64Counter
+	mismatches  metric.Int64Counter
+	inflight    sync.WaitGroup
+}
+
+// NewComparingClient returns a RemotePDPClient answering with primary and
+// comparing a sample of its calls against secondary
+func NewComparingClient(primary, secondary RemotePDPClient, options ComparisonOptions) (*comparingClient, error) {
+	if primary == nil || secondary == nil {
+		return nil, fmt.Errorf("need primary and secondary RemotePDPClient in creating comparing client")
+	}
+	if options.SampleRate < 0 || options.SampleRate > 1 {
+		return nil, fmt.Errorf("sample rate: %v is not valid, need a value between 0 and 1 in creating comparing client", options.SampleRate)
+	}
+	if options.Rand == nil {
+		options.Rand = rand.Float64
+	}
+	mp := options.MeterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName, metric.WithInstrumentationVersion(version))
+
+	comparisons, err := meter.Int64Counter("checkaccess.comparison.calls",
+		metric.WithDescription("Number of CheckAccess calls compared between two clients"))
+	if err != nil {
+		return nil, err
+	}
+	mismatches, err := meter.Int64Counter("checkaccess.comparison.mismatches",
+		metric.WithDescription("Number of actions whose decisions diverged between two clients, by kind"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &comparingClient{
+		primary:     primary,
+		secondary:   secondary,
+		options:     options,
+		comparisons: comparisons,
+		mismatches:  mismatches,
+	}, nil
+}
+
+// CheckAccess returns the decisions of the primary client
+func (c *comparingClient) CheckAccess(ctx context.Context, authzReq AuthorizationRequest) (*AuthorizationDecisionResponse, error) {
+	if c.options.SampleRate == 0 || c.options.Rand() >= c.options.SampleRate {
+		return c.primary.CheckAccess(ctx, authzReq)
+	}
+
+	secondaryCtx := context.WithoutCancel(ctx)
+	var cancel context.CancelFunc = func() {}
+	if c.options.Timeout > 0 {
+		secondaryCtx, cancel = context.WithTimeout(secondaryCtx, c.options.Timeout)
+	}
+	type outcome struct {
+		res *AuthorizationDecisionResponse
+		err error
+	}
+	secondary := make(chan outcome, 1)
+	go func() {
+		defer cancel()
+		res, err := c.secondary.CheckAccess(secondaryCtx, authzReq)
+		secondary <- outcome{res, err}
+	}()
+
+	res, err := c.primary.CheckAccess(ctx, authzReq)
+
+	// the caller owns res, the comparison works on its own copy
+	var primaryRes *AuthorizationDecisionResponse
+	if res != nil {
+		primaryRes = copyResponse(res)
+	}
+	c.inflight.Add(1)
+	go func() {
+		defer c.inflight.Done()
+		s := <-secondary
+		c.compare(secondaryCtx, Comparison{
+			Request:           authzReq,
+			PrimaryResponse:   primaryRes,
+			PrimaryErr:        err,
+			SecondaryResponse: s.res,
+			SecondaryErr:      s.err,
+		})
+	}()
+
+	return res, err
+}
+
+// CreateAuthorizationRequest delegates to the primary client
+func (c *comparingClient) CreateAuthorizationRequest(resourceId string, actions []string, jwtToken string) (*AuthorizationRequest, error) {
+	return c.primary.CreateAuthorizationRequest(resourceId, actions, jwtToken)
+}
+
+// Wait blocks until the comparisons in flight are recorded
+func (c *comparingClient) Wait() {
+	c.inflight.Wait()
+}
+
+// compare records the mismatches between both sides of comparison
+func (c *comparingClient) compare(ctx context.Context, comparison Comparison) {
+	comparison.Mismatches = compareResponses(comparison)
+	c.comparisons.Add(ctx, 1)
+	if len(comparison.Mismatches) == 0 {
+		return
+	}
+	for _, m := range comparison.Mismatches {
+		c.mismatches.Add(ctx, 1, metric.WithAttributes(attribute.String("checkaccess.mismatch_kind", string(m.Kind))))
+	}
+	if c.options.OnMismatch != nil {
+		c.options.OnMismatch(ctx, comparison)
+	}
+}
+
+// compareResponses returns the per action divergences of comparison
+func compareResponses(comparison Comparison) []ActionMismatch {
+	if (comparison.PrimaryErr == nil) != (comparison.SecondaryErr == nil) {
+		return []ActionMismatch{{Kind: MismatchError}}
+	}
+	if comparison.PrimaryErr != nil {
+		return nil
+	}
+
+	primary := decisionsByAction(comparison.PrimaryResponse)
+	secondary := decisionsByAction(comparison.SecondaryResponse)
+
+	var mismatches []ActionMismatch
+	for _, action := range comparison.Request.Actions {
+		p, s := primary[action.Id], secondary[action.Id]
+		var kind MismatchKind
+		switch {
+		case p == nil && s == nil:
+			continue
+		case p == nil || s == nil:
+			kind = MismatchMissing
+		case p.AccessDecision != s.AccessDecision:
+			kind = MismatchAccessDecision
+		case p.RoleAssignment.Id != s.RoleAssignment.Id:
+			kind = MismatchRoleAssignment
+		case p.DenyAssignment.Id != s.DenyAssignment.Id:
+			kind = MismatchDenyAssignment
+		default:
+			continue
+		}
+		mismatches = append(mismatches, ActionMismatch{ActionId: action.Id, Kind: kind, Primary: p, Secondary: s})
+	}
+	return mismatches
+}
+
+// decisionsByAction indexes the decisions of res by action ID
+func decisionsByAction(res *AuthorizationDecisionResponse) map[string]*AuthorizationDecision {
+	decisions := map[string]*AuthorizationDecision{}
+	if res == nil {
+		return decisions
+	}
+	for i := range res.Value {
+		decisions[res.Value[i].ActionId] = &res.Value[i]
+	}
+	return decisions
+}
@@ -0,0 +1,171 @@
+package client_test
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/fakepdp"
+)
+
+func TestComparingClient(t *testing.T) {
+	authzReq := client.AuthorizationRequest{Actions: []client.ActionInfo{{Id: "read"}, {Id: "write"}}}
+	respond := func(decisions ...client.AuthorizationDecision) func(context.Context, client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		return func(context.Context, client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+			return &client.AuthorizationDecisionResponse{Value: decisions}, nil
+		}
+	}
+	fail := func(context.Context, client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		return nil, errors.New("unavailable")
+	}
+	read := client.AuthorizationDecision{ActionId: "read", AccessDecision: client.Allowed, RoleAssignment: client.RoleAssignment{Id: "ra1"}}
+	write := client.AuthorizationDecision{ActionId: "write", AccessDecision: client.Allowed, RoleAssignment: client.RoleAssignment{Id: "ra1"}}
+
+	for _, tt := range []struct {
+		desc           string
+		primary        func(context.Context, client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error)
+		secondary      func(context.Context, client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error)
+		sampleRate     float64
+		wantMismatches []client.ActionMismatch
+		wantSecondary  int
+	}{
+		{
+			desc:          "no mismatch - identical decisions",
+			primary:       respond(read, write),
+			secondary:     respond(read, write),
+			sampleRate:    1,
+			wantSecondary: 1,
+		},
+		{
+			desc:       "mismatch - access decision and role assignment",
+			primary:    respond(read, write),
+			secondary:  respond(client.AuthorizationDecision{ActionId: "read", AccessDecision: client.Denied}, client.AuthorizationDecision{ActionId: "write", AccessDecision: client.Allowed, RoleAssignment: client.RoleAssignment{Id: "ra2"}}),
+			sampleRate: 1,
+			wantMismatches: []client.ActionMismatch{
+				{ActionId: "read", Kind: client.MismatchAccessDecision},
+				{ActionId: "write", Kind: client.MismatchRoleAssignment},
+			},
+			wantSecondary: 1,
+		},
+		{
+			desc:           "mismatch - missing decision",
+			primary:        respond(read, write),
+			secondary:      respond(read),
+			sampleRate:     1,
+			wantMismatches: []client.ActionMismatch{{ActionId: "write", Kind: client.MismatchMissing}},
+			wantSecondary:  1,
+		},
+		{
+			desc:           "mismatch - only one side failed",
+			primary:        respond(read, write),
+			secondary:      fail,
+			sampleRate:     1,
+			wantMismatches: []client.ActionMismatch{{Kind: client.MismatchError}},
+			wantSecondary:  1,
+		},
+		{
+			desc:       "not sampled - secondary isn't called",
+			primary:    respond(read, write),
+			secondary:  respond(read),
+			sampleRate: 0.5,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			primary := &fakepdp.Client{CheckAccessFunc: tt.primary}
+			secondary := &fakepdp.Client{CheckAccessFunc: tt.secondary}
+			var got []client.ActionMismatch
+
+			c, err := client.NewComparingClient(primary, secondary, client.ComparisonOptions{
+				SampleRate:    tt.sampleRate,
+				OnMismatch:    func(ctx context.Context, comparison client.Comparison) { got = comparison.Mismatches },
+				MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+				Rand:          func() float64 { return 0.75 },
+			})
+			if err != nil {
+				t.Fatalf("Unable to create comparing client: %v", err)
+			}
+
+			res, err := c.CheckAccess(context.Background(), authzReq)
+			c.Wait()
+			if err != nil {
+				t.Errorf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(&client.AuthorizationDecisionResponse{Value: []client.AuthorizationDecision{read, write}}, res); diff != "" {
+				t.Errorf("expected the primary response: %v", diff)
+			}
+			if secondary.Calls() != tt.wantSecondary {
+				t.Errorf("expected %d secondary calls but got %d", tt.wantSecondary, secondary.Calls())
+			}
+			if diff := cmp.Diff(tt.wantMismatches, got, cmpopts.IgnoreFields(client.ActionMismatch{}, "Primary", "Secondary")); diff != "" {
+				t.Errorf("incorrect mismatches: %v", diff)
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatal(err)
+			}
+			var mismatches int64
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name != "checkaccess.comparison.mismatches" {
+						continue
+					}
+					for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+						mismatches += dp.Value
+					}
+				}
+			}
+			if mismatches != int64(len(tt.wantMismatches)) {
+				t.Errorf("expected %d mismatches to be counted but got %d", len(tt.wantMismatches), mismatches)
+			}
+		})
+	}
+}
+
+func TestComparingClientCallerOwnsResponse(t *testing.T) {
+	allowed := func(context.Context, client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		return &client.AuthorizationDecisionResponse{Value: []client.AuthorizationDecision{{ActionId: "read", AccessDecision: client.Allowed}}}, nil
+	}
+	denied := func(context.Context, client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+		return &client.AuthorizationDecisionResponse{Value: []client.AuthorizationDecision{{ActionId: "read", AccessDecision: client.Denied}}}, nil
+	}
+	var got *client.AuthorizationDecisionResponse
+	c, err := client.NewComparingClient(&fakepdp.Client{CheckAccessFunc: allowed}, &fakepdp.Client{CheckAccessFunc: denied}, client.ComparisonOptions{
+		SampleRate: 1,
+		OnMismatch: func(ctx context.Context, comparison client.Comparison) { got = comparison.PrimaryResponse },
+	})
+	if err != nil {
+		t.Fatalf("Unable to create comparing client: %v", err)
+	}
+
+	res, err := c.CheckAccess(context.Background(), client.AuthorizationRequest{Actions: []client.ActionInfo{{Id: "read"}}})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	res.Value[0].AccessDecision = client.NotAllowed
+	c.Wait()
+
+	if got == nil || got.Value[0].AccessDecision != client.Allowed {
+		t.Errorf("expected the comparison to see the primary response as returned but got '%+v'", got)
+	}
+}
+
+func TestNewComparingClient(t *testing.T) {
+	pdp := fakepdp.New(client.Allowed)
+	if _, err := client.NewComparingClient(pdp, nil, client.ComparisonOptions{}); err == nil {
+		t.Errorf("expected error to be 'non-nil' for a missing secondary client")
+	}
+	if _, err := client.NewComparingClient(pdp, pdp, client.ComparisonOptions{SampleRate: 2}); err == nil {
+		t.Errorf("expected error to be 'non-nil' for an invalid sample rate")
+	}
+}
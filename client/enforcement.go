@@ -0,0 +1,163 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// this asserts that &enforcer{} would always implement RemotePDPClient
+var _ RemotePDPClient = &enforcer{}
+
+// EnforcementMode tells how an enforcer acts on the decisions of the PDP
+type EnforcementMode string
+
+// EnforcementMode possible values
+const (
+	// EnforcementOff doesn't call the PDP and allows every action
+	EnforcementOff EnforcementMode = "Off"
+	// EnforcementShadow calls the PDP, records the actions it would have
+	// denied and allows every action
+	EnforcementShadow EnforcementMode = "Shadow"
+	// EnforcementEnforce returns the decisions of the PDP as is
+	EnforcementEnforce EnforcementMode = "Enforce"
+)
+
+// ShadowDenial describes a CheckAccess call that was allowed only because
+// it ran in shadow mode
+type ShadowDenial struct {
+	Request AuthorizationRequest
+	// Decisions are the decisions of the PDP that were not Allowed
+	Decisions []AuthorizationDecision
+	// Err is set when the PDP call failed
+	Err error
+}
+
+// EnforcementOptions configures an enforcer
+type EnforcementOptions struct {
+	// Mode is the mode of requests not matched by TenantModes
+	Mode EnforcementMode
+
+	// EnforcePercentage promotes this percentage (0-100) of tenants from
+	// EnforcementShadow to EnforcementEnforce, whether Shadow is the global
+	// mode or the mode of their tenant. Tenants are bucketed by a stable hash
+	// of their ID, the tid claim of tokens, or of the object ID when the
+	// subject has no tenant, so a given tenant stays in the same mode while
+	// ramping up.
+	EnforcePercentage int
+
+	// TenantModes overrides the mode of specific tenant IDs
+	TenantModes map[string]EnforcementMode
+
+	// OnShadowDenial is called for every shadowed call the PDP didn't fully allow
+	OnShadowDenial func(context.Context, ShadowDenial)
+}
+
+// enforcer wraps a RemotePDPClient to roll out enforcement gradually
+type enforcer struct {
+	client  RemotePDPClient
+	options EnforcementOptions
+}
+
+// NewEnforcer returns a RemotePDPClient applying options to the decisions of client
+func NewEnforcer(client RemotePDPClient, options EnforcementOptions) (*enforcer, error) {
+	if client == nil {
+		return nil, fmt.Errorf("need RemotePDPClient in creating enforcer")
+	}
+	if !options.Mode.valid() {
+		return nil, fmt.Errorf("mode: %s is not valid, need a valid mode in creating enforcer", options.Mode)
+	}
+	for tenant, mode := range options.TenantModes {
+		if !mode.valid() {
+			return nil, fmt.Errorf("mode: %s of tenant %s is not valid, need a valid mode in creating enforcer", mode, tenant)
+		}
+	}
+	if options.EnforcePercentage < 0 || options.EnforcePercentage > 100 {
+		return nil, fmt.Errorf("enforce percentage: %d is not valid, need a value between 0 and 100 in creating enforcer", options.EnforcePercentage)
+	}
+	return &enforcer{client: client, options: options}, nil
+}
+
+// CheckAccess checks authzReq according to the mode of its subject
+func (e *enforcer) CheckAccess(ctx context.Context, authzReq AuthorizationRequest) (*AuthorizationDecisionResponse, error) {
+	switch e.modeOf(authzReq.Subject.Attributes) {
+	case EnforcementOff:
+		return allowAll(authzReq), nil
+	case EnforcementShadow:
+		res, err := e.client.CheckAccess(ctx, authzReq)
+		if err != nil {
+			e.recordShadowDenial(ctx, ShadowDenial{Request: authzReq, Err: err})
+			return allowAll(authzReq), nil
+		}
+		var denied []AuthorizationDecision
+		for _, decision := range res.Value {
+			if decision.AccessDecision != Allowed {
+				denied = append(denied, decision)
+			}
+		}
+		if len(denied) > 0 {
+			e.recordShadowDenial(ctx, ShadowDenial{Request: authzReq, Decisions: denied})
+		}
+		return allowAll(authzReq), nil
+	default:
+		return e.client.CheckAccess(ctx, authzReq)
+	}
+}
+
+// CreateAuthorizationRequest delegates to the wrapped client
+func (e *enforcer) CreateAuthorizationRequest(resourceId string, actions []string, jwtToken string) (*AuthorizationRequest, error) {
+	return e.client.CreateAuthorizationRequest(resourceId, actions, jwtToken)
+}
+
+// modeOf returns the mode applied to subject: the mode of its tenant, or
+// the global one, promoted to EnforcementEnforce by EnforcePercentage when
+// it is EnforcementShadow
+func (e *enforcer) modeOf(subject SubjectAttributes) EnforcementMode {
+	mode := e.options.Mode
+	if tenantMode, ok := e.options.TenantModes[subject.TenantId]; ok && subject.TenantId != "" {
+		mode = tenantMode
+	}
+	if mode == EnforcementShadow && e.options.EnforcePercentage > 0 {
+		key := subject.TenantId
+		if key == "" {
+			key = subject.ObjectId
+		}
+		if rolloutBucket(key) < e.options.EnforcePercentage {
+			return EnforcementEnforce
+		}
+	}
+	return mode
+}
+
+func (e *enforcer) recordShadowDenial(ctx context.Context, denial ShadowDenial) {
+	if e.options.OnShadowDenial != nil {
+		e.options.OnShadowDenial(ctx, denial)
+	}
+}
+
+func (m EnforcementMode) valid() bool {
+	return m == EnforcementOff || m == EnforcementShadow || m == EnforcementEnforce
+}
+
+// rolloutBucket maps key to a stable bucket in [0, 100)
+func rolloutBucket(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+// allowAll returns a response allowing every action of authzReq
+func allowAll(authzReq AuthorizationRequest) *AuthorizationDecisionResponse {
+	res := &AuthorizationDecisionResponse{Value: []AuthorizationDecision{}}
+	for _, action := range authzReq.Actions {
+		res.Value = append(res.Value, AuthorizationDecision{
+			ActionId:       action.Id,
+			AccessDecision: Allowed,
+			IsDataAction:   action.IsDataAction,
+		})
+	}
+	return res
+}
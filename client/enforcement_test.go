@@ -0,0 +1,227 @@
+package client_test
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/fakepdp"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/tokens"
+)
+
+func TestNewEnforcer(t *testing.T) {
+	pdp := fakepdp.New(client.Allowed)
+	for _, tt := range []struct {
+		desc        string
+		client      client.RemotePDPClient
+		options     client.EnforcementOptions
+		expectedErr bool
+	}{
+		{desc: "fail - missing client", options: client.EnforcementOptions{Mode: client.EnforcementEnforce}, expectedErr: true},
+		{desc: "fail - invalid mode", client: pdp, options: client.EnforcementOptions{Mode: "Audit"}, expectedErr: true},
+		{desc: "fail - invalid tenant mode", client: pdp, options: client.EnforcementOptions{Mode: client.EnforcementOff, TenantModes: map[string]client.EnforcementMode{"tid": ""}}, expectedErr: true},
+		{desc: "fail - invalid percentage", client: pdp, options: client.EnforcementOptions{Mode: client.EnforcementShadow, EnforcePercentage: 101}, expectedErr: true},
+		{desc: "success - valid options", client: pdp, options: client.EnforcementOptions{Mode: client.EnforcementShadow, EnforcePercentage: 50}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := client.NewEnforcer(tt.client, tt.options)
+			if tt.expectedErr != (err != nil) {
+				t.Errorf("expected error to be %v but got '%v'", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestEnforcerCheckAccess(t *testing.T) {
+	authzReq := client.AuthorizationRequest{
+		Subject: client.SubjectInfo{Attributes: client.SubjectAttributes{ObjectId: "oid", TenantId: "tid"}},
+		Actions: []client.ActionInfo{{Id: "read"}, {Id: "write"}},
+	}
+	allowed := &client.AuthorizationDecisionResponse{Value: []client.AuthorizationDecision{
+		{ActionId: "read", AccessDecision: client.Allowed},
+		{ActionId: "write", AccessDecision: client.Allowed},
+	}}
+	denied := &client.AuthorizationDecisionResponse{Value: []client.AuthorizationDecision{
+		{ActionId: "read", AccessDecision: client.Denied},
+		{ActionId: "write", AccessDecision: client.Denied},
+	}}
+
+	for _, tt := range []struct {
+		desc            string
+		options         client.EnforcementOptions
+		pdpErr          error
+		wantResponse    *client.AuthorizationDecisionResponse
+		wantErr         bool
+		wantPDPCalls    int
+		wantShadowCount int
+	}{
+		{
+			desc:         "off - allow without calling the PDP",
+			options:      client.EnforcementOptions{Mode: client.EnforcementOff},
+			wantResponse: allowed,
+		},
+		{
+			desc:            "shadow - allow and record the denials",
+			options:         client.EnforcementOptions{Mode: client.EnforcementShadow},
+			wantResponse:    allowed,
+			wantPDPCalls:    1,
+			wantShadowCount: 1,
+		},
+		{
+			desc:            "shadow - allow and record PDP errors",
+			options:         client.EnforcementOptions{Mode: client.EnforcementShadow},
+			pdpErr:          errors.New("unavailable"),
+			wantResponse:    allowed,
+			wantPDPCalls:    1,
+			wantShadowCount: 1,
+		},
+		{
+			desc:         "enforce - return the PDP decisions",
+			options:      client.EnforcementOptions{Mode: client.EnforcementEnforce},
+			wantResponse: denied,
+			wantPDPCalls: 1,
+		},
+		{
+			desc:         "enforce - return the PDP errors",
+			options:      client.EnforcementOptions{Mode: client.EnforcementEnforce},
+			pdpErr:       errors.New("unavailable"),
+			wantErr:      true,
+			wantPDPCalls: 1,
+		},
+		{
+			desc:         "tenant override - enforce a shadowed tenant",
+			options:      client.EnforcementOptions{Mode: client.EnforcementShadow, TenantModes: map[string]client.EnforcementMode{"tid": client.EnforcementEnforce}},
+			wantResponse: denied,
+			wantPDPCalls: 1,
+		},
+		{
+			desc:         "percentage - enforce every tenant at 100%",
+			options:      client.EnforcementOptions{Mode: client.EnforcementShadow, EnforcePercentage: 100},
+			wantResponse: denied,
+			wantPDPCalls: 1,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			pdp := &fakepdp.Client{Default: client.Denied, Err: tt.pdpErr}
+			shadowCount := 0
+			tt.options.OnShadowDenial = func(ctx context.Context, denial client.ShadowDenial) { shadowCount++ }
+
+			e, err := client.NewEnforcer(pdp, tt.options)
+			if err != nil {
+				t.Fatalf("Unable to create enforcer: %v", err)
+			}
+			res, err := e.CheckAccess(context.Background(), authzReq)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error to be %v but got '%v'", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.wantResponse, res); diff != "" {
+				t.Errorf("incorrect response: %v", diff)
+			}
+			if pdp.Calls() != tt.wantPDPCalls {
+				t.Errorf("expected %d PDP calls but got %d", tt.wantPDPCalls, pdp.Calls())
+			}
+			if shadowCount != tt.wantShadowCount {
+				t.Errorf("expected %d shadow denials but got %d", tt.wantShadowCount, shadowCount)
+			}
+		})
+	}
+}
+
+func TestEnforcerTenantOfToken(t *testing.T) {
+	minter, err := tokens.NewMinter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc       string
+		options    client.EnforcementOptions
+		tenantId   string
+		wantDenied bool
+	}{
+		{
+			desc:       "tenant override - enforce the tenant of the token",
+			options:    client.EnforcementOptions{Mode: client.EnforcementShadow, TenantModes: map[string]client.EnforcementMode{"tid-enforced": client.EnforcementEnforce}},
+			tenantId:   "tid-enforced",
+			wantDenied: true,
+		},
+		{
+			desc:     "tenant override - shadow the other tenants",
+			options:  client.EnforcementOptions{Mode: client.EnforcementShadow, TenantModes: map[string]client.EnforcementMode{"tid-enforced": client.EnforcementEnforce}},
+			tenantId: "tid-other",
+		},
+		{
+			desc:       "percentage - applies to a tenant shadowed by its override",
+			options:    client.EnforcementOptions{Mode: client.EnforcementOff, EnforcePercentage: 100, TenantModes: map[string]client.EnforcementMode{"tid-ramp": client.EnforcementShadow}},
+			tenantId:   "tid-ramp",
+			wantDenied: true,
+		},
+		{
+			desc:     "percentage - doesn't apply to the tenants off",
+			options:  client.EnforcementOptions{Mode: client.EnforcementOff, EnforcePercentage: 100, TenantModes: map[string]client.EnforcementMode{"tid-ramp": client.EnforcementShadow}},
+			tenantId: "tid-other",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			e, err := client.NewEnforcer(fakepdp.New(client.Denied), tt.options)
+			if err != nil {
+				t.Fatalf("Unable to create enforcer: %v", err)
+			}
+			token, err := minter.Mint(tokens.Options{ObjectId: "oid", TenantId: tt.tenantId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			authzReq, err := e.CreateAuthorizationRequest("/subscriptions/sub", []string{"read"}, token)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			res, err := e.CheckAccess(context.Background(), *authzReq)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if denied := res.Value[0].AccessDecision == client.Denied; denied != tt.wantDenied {
+				t.Errorf("expected denied to be %v but got '%+v'", tt.wantDenied, res.Value)
+			}
+		})
+	}
+}
+
+func TestEnforcePercentage(t *testing.T) {
+	e, err := client.NewEnforcer(fakepdp.New(client.Denied), client.EnforcementOptions{Mode: client.EnforcementShadow, EnforcePercentage: 30})
+	if err != nil {
+		t.Fatalf("Unable to create enforcer: %v", err)
+	}
+	// the PDP denies everything, so only the enforced tenants are denied
+	isEnforced := func(tenantId string) bool {
+		res, err := e.CheckAccess(context.Background(), client.AuthorizationRequest{
+			Subject: client.SubjectInfo{Attributes: client.SubjectAttributes{TenantId: tenantId}},
+			Actions: []client.ActionInfo{{Id: "read"}},
+		})
+		if err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+		return res.Value[0].AccessDecision == client.Denied
+	}
+
+	enforced := 0
+	for i := 0; i < 1000; i++ {
+		tenantId := fmt.Sprintf("tenant-%d", i)
+		mode := isEnforced(tenantId)
+		if mode != isEnforced(tenantId) {
+			t.Fatalf("expected the mode of %s to be stable", tenantId)
+		}
+		if mode {
+			enforced++
+		}
+	}
+	if enforced < 250 || enforced > 350 {
+		t.Errorf("expected about 30%% of tenants to be enforced but got %d/1000", enforced)
+	}
+}
@@ -0,0 +1,112 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Keys of the environment attributes ABAC conditions rely on
+const (
+	EnvironmentUtcNow          = "UtcNow"
+	EnvironmentIsPrivateLink   = "isPrivateLink"
+	EnvironmentPrivateEndpoint = "Microsoft.Network/privateEndpoints"
+	EnvironmentSubnet          = "Microsoft.Network/virtualNetworks/subnets"
+)
+
+// environmentTimeFormat is the format of the UtcNow attribute
+const environmentTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+// Environment is the typed form of EnvironmentInfo
+type Environment struct {
+	UtcNow time.Time
+	// IsPrivateLink tells whether the request came through a private endpoint
+	IsPrivateLink bool
+	// PrivateEndpointId is the ARM ID of the private endpoint of the request
+	PrivateEndpointId string
+	// SubnetId is the ARM ID of the subnet the request came from
+	SubnetId string
+	// Extra are more attributes, serialized as they are
+	Extra Attributes
+}
+
+// Info serializes the environment in the format the PDP expects
+func (e Environment) Info() EnvironmentInfo {
+	attrs := Attributes{}
+	for k, v := range e.Extra {
+		attrs[k] = v
+	}
+	if !e.UtcNow.IsZero() {
+		attrs[EnvironmentUtcNow] = e.UtcNow.UTC().Format(environmentTimeFormat)
+	}
+	attrs[EnvironmentIsPrivateLink] = e.IsPrivateLink
+	if e.PrivateEndpointId != "" {
+		attrs[EnvironmentPrivateEndpoint] = e.PrivateEndpointId
+	}
+	if e.SubnetId != "" {
+		attrs[EnvironmentSubnet] = e.SubnetId
+	}
+	return EnvironmentInfo{Attributes: attrs}
+}
+
+// EnvironmentBuilderOptions configures an EnvironmentBuilder.
+//
+// The private link headers are trusted as they are, so they must be set by
+// the ingress in front of the service, which must also strip them from the
+// incoming requests: otherwise any client can claim to come through a
+// private endpoint. There are no default header names, the private link
+// metadata are only read from the headers configured here.
+type EnvironmentBuilderOptions struct {
+	// Now returns the current time, time.Now when nil
+	Now func() time.Time
+	// PrivateEndpointHeader is the header the ingress sets with the private
+	// endpoint ID. When empty, no request is through private link.
+	PrivateEndpointHeader string
+	// SubnetHeader is the header the ingress sets with the subnet ID. When
+	// empty, the subnet of the requests isn't known.
+	SubnetHeader string
+}
+
+// EnvironmentBuilder derives the Environment of incoming requests
+type EnvironmentBuilder struct {
+	now                   func() time.Time
+	privateEndpointHeader string
+	subnetHeader          string
+}
+
+// NewEnvironmentBuilder returns an EnvironmentBuilder
+func NewEnvironmentBuilder(options *EnvironmentBuilderOptions) *EnvironmentBuilder {
+	if options == nil {
+		options = &EnvironmentBuilderOptions{}
+	}
+	b := &EnvironmentBuilder{
+		now:                   time.Now,
+		privateEndpointHeader: options.PrivateEndpointHeader,
+		subnetHeader:          options.SubnetHeader,
+	}
+	if options.Now != nil {
+		b.now = options.Now
+	}
+	return b
+}
+
+// FromRequest returns the Environment of r: the current time and the
+// private link metadata of the configured headers. A request is through
+// private link when it has a private endpoint ID.
+func (b *EnvironmentBuilder) FromRequest(r *http.Request) Environment {
+	env := Environment{UtcNow: b.now()}
+	if r == nil {
+		return env
+	}
+	if b.privateEndpointHeader != "" {
+		env.PrivateEndpointId = strings.TrimSpace(r.Header.Get(b.privateEndpointHeader))
+	}
+	if b.subnetHeader != "" {
+		env.SubnetId = strings.TrimSpace(r.Header.Get(b.subnetHeader))
+	}
+	env.IsPrivateLink = env.PrivateEndpointId != ""
+	return env
+}
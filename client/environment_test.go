@@ -0,0 +1,92 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnvironmentBuilder(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.FixedZone("PDT", -7*3600))
+	endpointId := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/privateEndpoints/pe"
+	subnetId := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/default"
+
+	for _, tt := range []struct {
+		name    string
+		options *EnvironmentBuilderOptions
+		header  http.Header
+		want    Attributes
+	}{
+		{
+			name:    "pass - public request",
+			options: &EnvironmentBuilderOptions{Now: func() time.Time { return now }},
+			want:    Attributes{EnvironmentUtcNow: "2024-05-01T17:30:00.0000000Z", EnvironmentIsPrivateLink: false},
+		},
+		{
+			name:    "pass - headers not configured are ignored",
+			options: &EnvironmentBuilderOptions{Now: func() time.Time { return now }},
+			header: http.Header{
+				"X-Ms-Private-Endpoint-Id":        {endpointId},
+				"X-Ms-Private-Endpoint-Subnet-Id": {subnetId},
+				"X-Pe":                            {endpointId},
+			},
+			want: Attributes{EnvironmentUtcNow: "2024-05-01T17:30:00.0000000Z", EnvironmentIsPrivateLink: false},
+		},
+		{
+			name: "pass - private link request",
+			options: &EnvironmentBuilderOptions{
+				Now:                   func() time.Time { return now },
+				PrivateEndpointHeader: "x-pe",
+				SubnetHeader:          "x-subnet",
+			},
+			header: http.Header{"X-Pe": {endpointId}, "X-Subnet": {subnetId}},
+			want: Attributes{
+				EnvironmentUtcNow:          "2024-05-01T17:30:00.0000000Z",
+				EnvironmentIsPrivateLink:   true,
+				EnvironmentPrivateEndpoint: endpointId,
+				EnvironmentSubnet:          subnetId,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			got := NewEnvironmentBuilder(tt.options).FromRequest(r).Info()
+			if diff := cmp.Diff(tt.want, got.Attributes); diff != "" {
+				t.Errorf("incorrect environment: %v", diff)
+			}
+		})
+	}
+}
+
+func TestEnvironmentInfoJSON(t *testing.T) {
+	env := Environment{
+		UtcNow: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Extra:  Attributes{"custom": "value"},
+	}
+	payload, err := json.Marshal(AuthorizationRequest{Environment: env.Info()})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	var got struct {
+		Environment struct {
+			Attributes map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	want := map[string]interface{}{"UtcNow": "2024-05-01T00:00:00.0000000Z", "isPrivateLink": false, "custom": "value"}
+	if diff := cmp.Diff(want, got.Environment.Attributes); diff != "" {
+		t.Errorf("incorrect payload: %v", diff)
+	}
+}
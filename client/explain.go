@@ -0,0 +1,189 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Codes of the ErrorDetail of an Explanation
+const (
+	ExplanationCodeAllowed    = "AuthorizationGranted"
+	ExplanationCodeNotAllowed = "AuthorizationFailed"
+	ExplanationCodeDenied     = "DenyAssignmentAuthorizationFailed"
+)
+
+// RoleDefinitionCatalog resolves the names of role definitions
+type RoleDefinitionCatalog interface {
+	// RoleDefinitionName returns the name of the role definition with the
+	// given ID, if known
+	RoleDefinitionName(roleDefinitionId string) (string, bool)
+}
+
+// RoleDefinitionMap is a RoleDefinitionCatalog keyed by the GUID of the role
+// definitions. Full role definition IDs are matched on their last segment.
+type RoleDefinitionMap map[string]string
+
+func (m RoleDefinitionMap) RoleDefinitionName(roleDefinitionId string) (string, bool) {
+	guid := strings.ToLower(path.Base(roleDefinitionId))
+	for id, name := range m {
+		if strings.ToLower(path.Base(id)) == guid {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// BuiltInRoleDefinitions are the names of common Azure built-in roles
+var BuiltInRoleDefinitions = RoleDefinitionMap{
+	"8e3af657-a8ff-443c-a75c-2fe8c4bcb635": "Owner",
+	"b24988ac-6180-42a0-ab88-20f7382dd24c": "Contributor",
+	"acdd72a7-3385-48ef-bd42-f606fba81ae7": "Reader",
+	"18d7d88d-d35e-4fb5-a5c3-7773c20a72d9": "User Access Administrator",
+	"f58310d9-a9f6-439a-9e8d-f62e7b41a168": "Role Based Access Control Administrator",
+}
+
+// ExplainOptions configures ExplainDecision
+type ExplainOptions struct {
+	// Catalog resolves role definition names. Names are omitted when nil.
+	Catalog RoleDefinitionCatalog
+}
+
+// Explanation tells why an action was allowed, not allowed or denied
+type Explanation struct {
+	ActionId           string         `json:"actionId"`
+	AccessDecision     AccessDecision `json:"accessDecision"`
+	RoleAssignmentId   string         `json:"roleAssignmentId,omitempty"`
+	RoleDefinitionId   string         `json:"roleDefinitionId,omitempty"`
+	RoleDefinitionName string         `json:"roleDefinitionName,omitempty"`
+	Scope              string         `json:"scope,omitempty"`
+	Condition          string         `json:"condition,omitempty"`
+	DenyAssignmentId   string         `json:"denyAssignmentId,omitempty"`
+	// Message is the readable narrative of the decision
+	Message string `json:"message"`
+}
+
+// ErrorDetail is an entry of the details of an ARM error response
+type ErrorDetail struct {
+	Code           string                `json:"code"`
+	Message        string                `json:"message"`
+	Target         string                `json:"target,omitempty"`
+	Details        []ErrorDetail         `json:"details,omitempty"`
+	AdditionalInfo []ErrorAdditionalInfo `json:"additionalInfo,omitempty"`
+}
+
+// ErrorAdditionalInfo is the additional info of an ARM error detail
+type ErrorAdditionalInfo struct {
+	Type string      `json:"type"`
+	Info interface{} `json:"info"`
+}
+
+// ExplainDecision explains decision in a readable narrative: the role
+// assignment that granted it, at what scope and under what condition, or
+// the deny assignment that blocked it
+func ExplainDecision(decision AuthorizationDecision, options *ExplainOptions) Explanation {
+	if options == nil {
+		options = &ExplainOptions{}
+	}
+	e := Explanation{
+		ActionId:         decision.ActionId,
+		AccessDecision:   decision.AccessDecision,
+		RoleAssignmentId: decision.RoleAssignment.Id,
+		RoleDefinitionId: decision.RoleAssignment.RoleDefinitionId,
+		Scope:            decision.RoleAssignment.Scope,
+		Condition:        decision.RoleAssignment.Condition,
+		DenyAssignmentId: decision.DenyAssignment.Id,
+	}
+	if e.RoleDefinitionId != "" && options.Catalog != nil {
+		e.RoleDefinitionName, _ = options.Catalog.RoleDefinitionName(e.RoleDefinitionId)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Action '%s' ", decision.ActionId)
+	switch decision.AccessDecision {
+	case Allowed:
+		b.WriteString("is allowed")
+		if e.RoleAssignmentId != "" || e.RoleDefinitionId != "" {
+			b.WriteString(" by " + e.assignment())
+		}
+		if e.Condition != "" {
+			fmt.Fprintf(&b, " under the condition '%s'", e.Condition)
+		}
+	case Denied:
+		b.WriteString("is denied")
+		if e.DenyAssignmentId != "" {
+			fmt.Fprintf(&b, " by the deny assignment '%s'", e.DenyAssignmentId)
+		}
+		b.WriteString(", which takes precedence over role assignments")
+	case NotAllowed:
+		if e.Condition != "" {
+			fmt.Fprintf(&b, "is not allowed: %s grants it only under the condition '%s', which is not met", e.assignment(), e.Condition)
+		} else {
+			b.WriteString("is not allowed: no role assignment grants it")
+		}
+	default:
+		fmt.Fprintf(&b, "has the unknown access decision '%s'", decision.AccessDecision)
+	}
+	b.WriteString(".")
+	e.Message = b.String()
+	return e
+}
+
+// ExplainDecisions explains every decision of res
+func ExplainDecisions(res *AuthorizationDecisionResponse, options *ExplainOptions) []Explanation {
+	if res == nil {
+		return nil
+	}
+	explanations := make([]Explanation, 0, len(res.Value))
+	for _, decision := range res.Value {
+		explanations = append(explanations, ExplainDecision(decision, options))
+	}
+	return explanations
+}
+
+// String returns the message of the explanation
+func (e Explanation) String() string {
+	return e.Message
+}
+
+// ErrorDetail returns the explanation as an ARM error detail whose target
+// is the action and whose additional info is the explanation itself
+func (e Explanation) ErrorDetail() ErrorDetail {
+	code := ExplanationCodeNotAllowed
+	switch e.AccessDecision {
+	case Allowed:
+		code = ExplanationCodeAllowed
+	case Denied:
+		code = ExplanationCodeDenied
+	}
+	return ErrorDetail{
+		Code:    code,
+		Message: e.Message,
+		Target:  e.ActionId,
+		AdditionalInfo: []ErrorAdditionalInfo{
+			{Type: "AuthorizationDecision", Info: e},
+		},
+	}
+}
+
+// assignment describes the role assignment of the explanation
+func (e Explanation) assignment() string {
+	var b strings.Builder
+	b.WriteString("the role assignment")
+	if e.RoleAssignmentId != "" {
+		fmt.Fprintf(&b, " '%s'", e.RoleAssignmentId)
+	}
+	switch {
+	case e.RoleDefinitionName != "":
+		fmt.Fprintf(&b, " of the role '%s'", e.RoleDefinitionName)
+	case e.RoleDefinitionId != "":
+		fmt.Fprintf(&b, " of the role definition '%s'", e.RoleDefinitionId)
+	}
+	if e.Scope != "" {
+		fmt.Fprintf(&b, " at the scope '%s'", e.Scope)
+	}
+	return b.String()
+}
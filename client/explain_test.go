@@ -0,0 +1,94 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExplainDecision(t *testing.T) {
+	readerId := "/subscriptions/sub/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7"
+	customId := "/subscriptions/sub/providers/Microsoft.Authorization/roleDefinitions/11111111-1111-1111-1111-111111111111"
+
+	for _, tt := range []struct {
+		name        string
+		decision    AuthorizationDecision
+		options     *ExplainOptions
+		wantMessage string
+		wantCode    string
+	}{
+		{
+			name: "pass - allowed with a role name from the catalog",
+			decision: AuthorizationDecision{
+				ActionId:       "read",
+				AccessDecision: Allowed,
+				RoleAssignment: RoleAssignment{Id: "ra1", RoleDefinitionId: readerId, Scope: "/subscriptions/sub"},
+			},
+			options:     &ExplainOptions{Catalog: BuiltInRoleDefinitions},
+			wantMessage: "Action 'read' is allowed by the role assignment 'ra1' of the role 'Reader' at the scope '/subscriptions/sub'.",
+			wantCode:    ExplanationCodeAllowed,
+		},
+		{
+			name: "pass - allowed under a condition without catalog",
+			decision: AuthorizationDecision{
+				ActionId:       "blobs/read",
+				AccessDecision: Allowed,
+				RoleAssignment: RoleAssignment{Id: "ra2", RoleDefinitionId: customId, Scope: "/subscriptions/sub/resourceGroups/rg", Condition: "@Resource[tag] == 'x'"},
+			},
+			wantMessage: "Action 'blobs/read' is allowed by the role assignment 'ra2' of the role definition '" + customId + "' at the scope '/subscriptions/sub/resourceGroups/rg' under the condition '@Resource[tag] == 'x''.",
+			wantCode:    ExplanationCodeAllowed,
+		},
+		{
+			name: "pass - denied by a deny assignment",
+			decision: AuthorizationDecision{
+				ActionId:       "delete",
+				AccessDecision: Denied,
+				DenyAssignment: RoleDefinition{Id: "da1"},
+			},
+			wantMessage: "Action 'delete' is denied by the deny assignment 'da1', which takes precedence over role assignments.",
+			wantCode:    ExplanationCodeDenied,
+		},
+		{
+			name:        "pass - not allowed",
+			decision:    AuthorizationDecision{ActionId: "write", AccessDecision: NotAllowed},
+			wantMessage: "Action 'write' is not allowed: no role assignment grants it.",
+			wantCode:    ExplanationCodeNotAllowed,
+		},
+		{
+			name: "pass - not allowed because a condition is not met",
+			decision: AuthorizationDecision{
+				ActionId:       "write",
+				AccessDecision: NotAllowed,
+				RoleAssignment: RoleAssignment{Id: "ra3", Condition: "false"},
+			},
+			wantMessage: "Action 'write' is not allowed: the role assignment 'ra3' grants it only under the condition 'false', which is not met.",
+			wantCode:    ExplanationCodeNotAllowed,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ExplainDecision(tt.decision, tt.options)
+			if e.String() != tt.wantMessage {
+				t.Errorf("expected message\n%s\nbut got\n%s", tt.wantMessage, e.String())
+			}
+			detail := e.ErrorDetail()
+			if detail.Code != tt.wantCode || detail.Target != tt.decision.ActionId || detail.Message != tt.wantMessage {
+				t.Errorf("unexpected error detail %v", detail)
+			}
+			if _, err := json.Marshal(detail); err != nil {
+				t.Errorf("expected error to be 'nil' but got '%v'", err)
+			}
+		})
+	}
+}
+
+func TestRoleDefinitionMap(t *testing.T) {
+	catalog := RoleDefinitionMap{"/providers/Microsoft.Authorization/roleDefinitions/ABC": "Custom"}
+	if name, ok := catalog.RoleDefinitionName("/subscriptions/sub/providers/Microsoft.Authorization/roleDefinitions/abc"); !ok || name != "Custom" {
+		t.Errorf("expected Custom but got %s %v", name, ok)
+	}
+	if _, ok := catalog.RoleDefinitionName("def"); ok {
+		t.Error("expected def to be unknown")
+	}
+}
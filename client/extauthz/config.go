@@ -0,0 +1,130 @@
+package extauthz
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+// Package extauthz is an Envoy external authorization service implementing
+// the ext_authz HTTP protocol with a RemotePDPClient.
+//
+// Envoy forwards the method, path and headers of every request to the
+// service. The bearer token of the Authorization header is the subject, and
+// the route of the request, matched by path or selected by the name Envoy
+// sends in the route header, gives the resource and actions to check. A 200
+// lets Envoy forward the request, any other status is returned to the client.
+//
+// A config file looks like:
+//
+//	pathPrefix: /check
+//	routeHeader: x-route-name
+//	headers:
+//	  allowed:
+//	    x-authz-object-id: "{objectId}"
+//	  denied:
+//	    www-authenticate: Bearer
+//	routes:
+//	- name: widgets-read
+//	  method: GET
+//	  path: /widgets/{widgetName}
+//	  resource: /subscriptions/sub/resourceGroups/rg/providers/Microsoft.Contoso/widgets/{widgetName}
+//	  actions:
+//	  - id: Microsoft.Contoso/widgets/read
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/routepolicy"
+)
+
+// Placeholders of the configured header values
+const (
+	PlaceholderObjectId = "{objectId}"
+	PlaceholderTenantId = "{tenantId}"
+	PlaceholderResource = "{resource}"
+	PlaceholderRoute    = "{route}"
+)
+
+// Config maps the requests Envoy checks to AuthorizationRequests
+type Config struct {
+	// PathPrefix is the path_prefix of the http_service of Envoy, removed
+	// from the path of the check requests
+	PathPrefix string `yaml:"pathPrefix,omitempty" json:"pathPrefix,omitempty"`
+	// RouteHeader is the header Envoy sets to the name of the route of the
+	// request. The route is matched by path when it is empty or missing.
+	RouteHeader string `yaml:"routeHeader,omitempty" json:"routeHeader,omitempty"`
+	// DeniedStatus is the status of the denied requests, 403 when zero
+	DeniedStatus int `yaml:"deniedStatus,omitempty" json:"deniedStatus,omitempty"`
+	// Headers are the headers of the check responses
+	Headers Headers `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Routes are the route policy of the service
+	Routes []routepolicy.Route `yaml:"routes" json:"routes"`
+
+	policy *routepolicy.Policy
+}
+
+// Headers are the headers set on the check responses. Their values may hold
+// the {objectId}, {tenantId}, {resource} and {route} placeholders.
+type Headers struct {
+	// Allowed are set on the allowed responses. Envoy adds those listed in
+	// allowed_upstream_headers to the request it forwards.
+	Allowed map[string]string `yaml:"allowed,omitempty" json:"allowed,omitempty"`
+	// Denied are set on the denied responses. Envoy returns those listed in
+	// allowed_client_headers to the client.
+	Denied map[string]string `yaml:"denied,omitempty" json:"denied,omitempty"`
+}
+
+// LoadConfig reads and validates the config file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading the config file, err: %w", err)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses and validates a YAML or JSON config
+func ParseConfig(data []byte) (*Config, error) {
+	c := &Config{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("error while parsing the config, err: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the config and its routes
+func (c *Config) Validate() error {
+	if c.PathPrefix != "" && (!strings.HasPrefix(c.PathPrefix, "/") || strings.HasSuffix(c.PathPrefix, "/")) {
+		return fmt.Errorf("pathPrefix: %s is not valid, need a path without trailing slash", c.PathPrefix)
+	}
+	if c.DeniedStatus == 0 {
+		c.DeniedStatus = http.StatusForbidden
+	}
+	if c.DeniedStatus < 400 || c.DeniedStatus > 499 {
+		return fmt.Errorf("deniedStatus: %d is not valid, need a 4xx status", c.DeniedStatus)
+	}
+	for _, headers := range []map[string]string{c.Headers.Allowed, c.Headers.Denied} {
+		for name := range headers {
+			if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " :\t") {
+				return fmt.Errorf("headers: %q is not a valid header name", name)
+			}
+		}
+	}
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("need routes")
+	}
+
+	policy := &routepolicy.Policy{Routes: c.Routes}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	c.policy = policy
+	return nil
+}
@@ -0,0 +1,160 @@
+package extauthz
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/routepolicy"
+)
+
+// Options configures the ext_authz service
+type Options struct {
+	// Client decides the requests
+	Client client.RemotePDPClient
+	// Config maps the requests to AuthorizationRequests
+	Config *Config
+	// Subject returns the subject of the bearer token of a request, the
+	// claims of the token (client.TokenSubject) when nil. Tokens are not
+	// validated: Envoy must validate them first, with its jwt_authn filter.
+	Subject func(r *http.Request, token string) client.SubjectSource
+}
+
+// service is the http.Handler of the ext_authz check requests
+type service struct {
+	options Options
+}
+
+var _ http.Handler = &service{}
+
+// NewService returns the http.Handler answering the ext_authz HTTP check
+// requests of Envoy
+func NewService(options Options) (*service, error) {
+	if options.Client == nil {
+		return nil, fmt.Errorf("need client in creating service")
+	}
+	if options.Config == nil || options.Config.policy == nil {
+		return nil, fmt.Errorf("need validated config in creating service")
+	}
+	if options.Subject == nil {
+		options.Subject = func(r *http.Request, token string) client.SubjectSource {
+			return client.TokenSubject(token)
+		}
+	}
+	return &service{options: options}, nil
+}
+
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	config := s.options.Config
+	path := r.URL.Path
+	if config.PathPrefix != "" {
+		if path != config.PathPrefix && !strings.HasPrefix(path, config.PathPrefix+"/") {
+			s.deny(w, http.StatusForbidden, nil, nil, "", fmt.Sprintf("%s is not under the path prefix %s", path, config.PathPrefix))
+			return
+		}
+		path = strings.TrimPrefix(path, config.PathPrefix)
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	var match *routepolicy.Match
+	var ok bool
+	if name := r.Header.Get(config.RouteHeader); config.RouteHeader != "" && name != "" {
+		match, ok = config.policy.MatchNamed(name, r.Method, path)
+	} else {
+		match, ok = config.policy.Match(r.Method, path)
+	}
+	if !ok {
+		s.deny(w, config.DeniedStatus, nil, nil, "", fmt.Sprintf("No authorization policy maps %s %s.", r.Method, path))
+		return
+	}
+
+	token, ok := bearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.deny(w, http.StatusUnauthorized, nil, nil, match.Route.Name, "The request has no bearer token.")
+		return
+	}
+	subject, err := s.options.Subject(r, token).Subject(r.Context())
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		s.deny(w, http.StatusUnauthorized, nil, nil, match.Route.Name, "The bearer token is not valid.")
+		return
+	}
+
+	authzReq := match.AuthorizationRequest(r, subject)
+	res, err := s.options.Client.CheckAccess(r.Context(), authzReq)
+	if err != nil {
+		http.Error(w, "the request could not be authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	result := client.NewDecisionResult(authzReq.Actions, res)
+	if !result.AllAllowed() {
+		s.deny(w, config.DeniedStatus, &authzReq, res, match.Route.Name, "The request is not authorized.")
+		return
+	}
+	setHeaders(w, config.Headers.Allowed, placeholders(&authzReq, match.Route.Name))
+	w.WriteHeader(http.StatusOK)
+}
+
+// deny writes a denied response of status with the configured headers and
+// an ARM error body explaining the decisions of authzReq when known
+func (s *service) deny(w http.ResponseWriter, status int, authzReq *client.AuthorizationRequest, res *client.AuthorizationDecisionResponse, route, message string) {
+	setHeaders(w, s.options.Config.Headers.Denied, placeholders(authzReq, route))
+
+	var armErr *client.ARMErrorResponse
+	if authzReq != nil {
+		armErr = client.NewAuthorizationFailedError(*authzReq, res, nil)
+	}
+	if armErr == nil {
+		armErr = &client.ARMErrorResponse{Error: client.ErrorDetail{Code: client.ARMCodeAuthorizationFailed, Message: message}}
+	}
+	if status == http.StatusForbidden {
+		_ = armErr.Write(w)
+		return
+	}
+	http.Error(w, armErr.Error.Message, status)
+}
+
+// placeholders returns the values of the header placeholders
+func placeholders(authzReq *client.AuthorizationRequest, route string) map[string]string {
+	values := map[string]string{PlaceholderRoute: route}
+	if authzReq != nil {
+		values[PlaceholderObjectId] = authzReq.Subject.Attributes.ObjectId
+		values[PlaceholderTenantId] = authzReq.Subject.Attributes.TenantId
+		values[PlaceholderResource] = authzReq.Resource.Id
+	}
+	return values
+}
+
+// setHeaders sets the headers with their placeholders replaced by values.
+// Headers left empty are not set.
+func setHeaders(w http.ResponseWriter, headers, values map[string]string) {
+	for name, value := range headers {
+		for placeholder, v := range values {
+			value = strings.ReplaceAll(value, placeholder, v)
+		}
+		for _, placeholder := range []string{PlaceholderObjectId, PlaceholderTenantId, PlaceholderResource, PlaceholderRoute} {
+			value = strings.ReplaceAll(value, placeholder, "")
+		}
+		if value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+}
+
+// bearerToken returns the token of a "Bearer <token>" Authorization header
+func bearerToken(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
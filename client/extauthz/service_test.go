@@ -0,0 +1,213 @@
+package extauthz
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/fakepdp"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/tokens"
+)
+
+const widget = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Contoso/widgets/w1"
+
+func TestService(t *testing.T) {
+	config, err := LoadConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	minter, err := tokens.NewMinter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := minter.Mint(tokens.Options{ObjectId: "oid", TenantId: "tid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name          string
+		method        string
+		path          string
+		route         string
+		authorization string
+		err           error
+		wantStatus    int
+		wantAction    string
+		wantAttribute string
+		wantHeaders   map[string]string
+	}{
+		{
+			name:          "pass - allowed",
+			method:        http.MethodGet,
+			path:          "/check/widgets/w1",
+			authorization: "Bearer " + token,
+			wantStatus:    http.StatusOK,
+			wantAction:    "Microsoft.Contoso/widgets/read",
+			wantHeaders:   map[string]string{"x-authz-object-id": "oid", "x-authz-resource": widget},
+		},
+		{
+			name:          "pass - selected by route header",
+			method:        http.MethodGet,
+			path:          "/check/v2/w1/b1",
+			route:         "blobs-read",
+			authorization: "bearer " + token,
+			wantStatus:    http.StatusOK,
+			wantAction:    "Microsoft.Contoso/widgets/blobs/read",
+		},
+		{
+			name:          "fail - not allowed",
+			method:        http.MethodPut,
+			path:          "/check/widgets/w1?sku=premium",
+			authorization: "Bearer " + token,
+			wantStatus:    http.StatusForbidden,
+			wantAction:    "Microsoft.Contoso/widgets/write",
+			wantAttribute: "premium",
+			wantHeaders:   map[string]string{"x-authz-route": "widgets-write", "x-authz-object-id": ""},
+		},
+		{
+			name:          "fail - route header does not match",
+			method:        http.MethodGet,
+			path:          "/check/v2/w1/b1",
+			route:         "widgets-read",
+			authorization: "Bearer " + token,
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "fail - outside the path prefix",
+			method:        http.MethodGet,
+			path:          "/widgets/w1",
+			authorization: "Bearer " + token,
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:        "fail - no token",
+			method:      http.MethodGet,
+			path:        "/check/widgets/w1",
+			wantStatus:  http.StatusUnauthorized,
+			wantHeaders: map[string]string{"WWW-Authenticate": "Bearer", "x-authz-route": "widgets-read"},
+		},
+		{
+			name:          "fail - invalid token",
+			method:        http.MethodGet,
+			path:          "/check/widgets/w1",
+			authorization: "Bearer not-a-jwt",
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "fail - check access error",
+			method:        http.MethodGet,
+			path:          "/check/widgets/w1",
+			authorization: "Bearer " + token,
+			err:           errors.New("pdp unavailable"),
+			wantStatus:    http.StatusServiceUnavailable,
+			wantAction:    "Microsoft.Contoso/widgets/read",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pdp := &fakepdp.Client{
+				Decisions: map[string]client.AccessDecision{"Microsoft.Contoso/widgets/read": client.Allowed, "Microsoft.Contoso/widgets/blobs/read": client.Allowed},
+				Err:       tt.err,
+			}
+			s, err := NewService(Options{Client: pdp, Config: config})
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.route != "" {
+				r.Header.Set("x-route-name", tt.route)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status to be '%d' but got '%d': %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			for name, want := range tt.wantHeaders {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("expected header %s to be '%s' but got '%s'", name, want, got)
+				}
+			}
+			if w.Code == http.StatusForbidden {
+				var armErr client.ARMErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &armErr); err != nil || armErr.Error.Code != client.ARMCodeAuthorizationFailed {
+					t.Errorf("expected an AuthorizationFailed error but got '%s'", w.Body.String())
+				}
+			}
+
+			if tt.wantAction == "" {
+				if pdp.Calls() != 0 {
+					t.Errorf("expected no CheckAccess but got '%d'", pdp.Calls())
+				}
+				return
+			}
+			if pdp.Calls() != 1 {
+				t.Fatalf("expected 1 CheckAccess but got '%d'", pdp.Calls())
+			}
+			req := pdp.Requests()[0]
+			if req.Actions[0].Id != tt.wantAction || req.Resource.Id != widget || req.Subject.Attributes.ObjectId != "oid" {
+				t.Errorf("expected %s on %s by oid but got '%+v'", tt.wantAction, widget, req)
+			}
+			if got, _ := req.Resource.Attributes["Microsoft.Contoso/widgets:sku"].(string); got != tt.wantAttribute {
+				t.Errorf("expected sku attribute '%s' but got '%s'", tt.wantAttribute, got)
+			}
+		})
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:    "fail - unknown field",
+			config:  `{prefix: /check, routes: [{method: GET, path: /a, resource: /a, actions: [{id: read}]}]}`,
+			wantErr: "error while parsing the config",
+		},
+		{
+			name:    "fail - path prefix",
+			config:  `{pathPrefix: check/, routes: [{method: GET, path: /a, resource: /a, actions: [{id: read}]}]}`,
+			wantErr: "pathPrefix: check/ is not valid",
+		},
+		{
+			name:    "fail - denied status",
+			config:  `{deniedStatus: 200, routes: [{method: GET, path: /a, resource: /a, actions: [{id: read}]}]}`,
+			wantErr: "deniedStatus: 200 is not valid",
+		},
+		{
+			name:    "fail - header name",
+			config:  `{headers: {allowed: {"x a": v}}, routes: [{method: GET, path: /a, resource: /a, actions: [{id: read}]}]}`,
+			wantErr: `headers: "x a" is not a valid header name`,
+		},
+		{
+			name:    "fail - no routes",
+			config:  `{pathPrefix: /check}`,
+			wantErr: "need routes",
+		},
+		{
+			name:    "fail - invalid route",
+			config:  `{routes: [{method: GET, path: /a, resource: /a}]}`,
+			wantErr: "route 0 (GET /a): need actions",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.config))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("expected error to start with '%s' but got '%v'", tt.wantErr, err)
+			}
+		})
+	}
+}
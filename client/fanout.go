@@ -0,0 +1,263 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultFanOutConcurrency is the number of concurrent CheckAccess calls of
+// a fan-out when FanOutOptions.Concurrency is not set
+const defaultFanOutConcurrency = 8
+
+// FanOutOptions configures the fan-out APIs of the client
+type FanOutOptions struct {
+	// Concurrency is the maximum number of concurrent CheckAccess calls,
+	// defaultFanOutConcurrency when zero or negative
+	Concurrency int
+
+	// StopOnError stops a resource fan-out at the first failed call
+	StopOnError bool
+
+	// StopAfterAllowed, if positive, stops a resource fan-out once that many
+	// resources are allowed. As the calls are concurrent, more resources
+	// than StopAfterAllowed may be allowed in the result.
+	StopAfterAllowed int
+}
+
+// ErrFanOutStopped is the error of the resources a fan-out did not check
+// because StopOnError or StopAfterAllowed stopped it
+var ErrFanOutStopped = errors.New("fan-out stopped before checking the resource")
+
+func (o *FanOutOptions) concurrency() int {
+	if o == nil || o.Concurrency <= 0 {
+		return defaultFanOutConcurrency
+	}
+	return o.Concurrency
+}
+
+// SubjectResult is the outcome of the CheckAccess call of one subject.
+// Exactly one of Response and Err is set.
+type SubjectResult struct {
+	Response *AuthorizationDecisionResponse
+	Err      error
+}
+
+// CheckAccessForSubjects checks whether each of subjects can perform actions
+// on resource. The calls run concurrently, up to options.Concurrency at once,
+// and the failure of one subject does not fail the others: its error is in
+// its SubjectResult. The results are keyed by the ObjectId of the subjects.
+// A subject listed twice is checked once, and two subjects with the same
+// ObjectId but other attributes that differ are not valid.
+// An error is returned only when the input is not valid.
+func CheckAccessForSubjects(ctx context.Context, client RemotePDPClient, resource ResourceInfo, actions []ActionInfo, subjects []SubjectInfo, options *FanOutOptions) (map[string]SubjectResult, error) {
+	if client == nil {
+		return nil, fmt.Errorf("need client in checking access for subjects")
+	}
+	if resource.Id == "" {
+		return nil, fmt.Errorf("need resource id in checking access for subjects")
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("need actions in checking access for subjects")
+	}
+
+	requests := make(map[string]AuthorizationRequest, len(subjects))
+	indexes := make(map[string]int, len(subjects))
+	for i, subject := range subjects {
+		objectId := subject.Attributes.ObjectId
+		if objectId == "" {
+			return nil, fmt.Errorf("need object id of subject %d in checking access for subjects", i)
+		}
+		if j, ok := indexes[objectId]; ok {
+			if !reflect.DeepEqual(subjects[j], subject) {
+				return nil, fmt.Errorf("subject %d: object id %s duplicates subject %d with other attributes", i, objectId, j)
+			}
+			continue
+		}
+		indexes[objectId] = i
+		requests[objectId] = AuthorizationRequest{
+			Subject:  subject,
+			Actions:  actions,
+			Resource: resource,
+		}
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]SubjectResult, len(requests))
+	setResult := func(objectId string, res *AuthorizationDecisionResponse, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[objectId] = SubjectResult{Response: res, Err: err}
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, options.concurrency())
+	for objectId, authzReq := range requests {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			setResult(objectId, nil, ctx.Err())
+			continue
+		}
+		wg.Add(1)
+		go func(objectId string, authzReq AuthorizationRequest) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			res, err := client.CheckAccess(ctx, authzReq)
+			setResult(objectId, res, err)
+		}(objectId, authzReq)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// CheckAccessForSubjects is the package CheckAccessForSubjects with r
+func (r *remotePDPClient) CheckAccessForSubjects(ctx context.Context, resource ResourceInfo, actions []ActionInfo, subjects []SubjectInfo, options *FanOutOptions) (map[string]SubjectResult, error) {
+	return CheckAccessForSubjects(ctx, r, resource, actions, subjects, options)
+}
+
+// ResourceResult is the outcome of the CheckAccess call of one resource.
+// Exactly one of Response and Err is set.
+type ResourceResult struct {
+	// Index is the position of the resource in the resource IDs of the fan-out
+	Index      int
+	ResourceId string
+	// Allowed is true when every requested action is allowed on the
+	// resource. An action missing from the response is not allowed.
+	Allowed  bool
+	Response *AuthorizationDecisionResponse
+	Err      error
+}
+
+// ResourceResults are the results of a resource fan-out, in the order of
+// its resource IDs
+type ResourceResults []ResourceResult
+
+// AllowedResourceIds returns the IDs of the allowed resources, in order
+func (r ResourceResults) AllowedResourceIds() []string {
+	var ids []string
+	for _, result := range r {
+		if result.Allowed {
+			ids = append(ids, result.ResourceId)
+		}
+	}
+	return ids
+}
+
+// CheckAccessForResources checks whether subject can perform actions on each
+// of resourceIds, up to options.Concurrency calls at once, and returns one
+// result per resource in the order of resourceIds. The failure of one
+// resource does not fail the others unless options.StopOnError is set.
+// An error is returned only when the input is not valid.
+func CheckAccessForResources(ctx context.Context, client RemotePDPClient, subject SubjectInfo, actions []ActionInfo, resourceIds []string, options *FanOutOptions) (ResourceResults, error) {
+	stream, err := StreamCheckAccessForResources(ctx, client, subject, actions, resourceIds, options)
+	if err != nil {
+		return nil, err
+	}
+	results := make(ResourceResults, len(resourceIds))
+	for result := range stream {
+		results[result.Index] = result
+	}
+	return results, nil
+}
+
+// CheckAccessForResources is the package CheckAccessForResources with r
+func (r *remotePDPClient) CheckAccessForResources(ctx context.Context, subject SubjectInfo, actions []ActionInfo, resourceIds []string, options *FanOutOptions) (ResourceResults, error) {
+	return CheckAccessForResources(ctx, r, subject, actions, resourceIds, options)
+}
+
+// StreamCheckAccessForResources is CheckAccessForResources sending each
+// result on the returned channel as soon as it is known. The channel is
+// buffered for every resource, so the caller may stop reading at any time,
+// and is closed once all the resources have a result.
+func StreamCheckAccessForResources(ctx context.Context, client RemotePDPClient, subject SubjectInfo, actions []ActionInfo, resourceIds []string, options *FanOutOptions) (<-chan ResourceResult, error) {
+	if client == nil {
+		return nil, fmt.Errorf("need client in checking access for resources")
+	}
+	if subject.Attributes.ObjectId == "" {
+		return nil, fmt.Errorf("need subject object id in checking access for resources")
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("need actions in checking access for resources")
+	}
+	for i, id := range resourceIds {
+		if id == "" {
+			return nil, fmt.Errorf("need id of resource %d in checking access for resources", i)
+		}
+	}
+	if options == nil {
+		options = &FanOutOptions{}
+	}
+
+	out := make(chan ResourceResult, len(resourceIds))
+	ctx, stop := context.WithCancelCause(ctx)
+	var allowed int32
+	send := func(result ResourceResult) {
+		if result.Err == nil {
+			result.Allowed = NewDecisionResult(actions, result.Response).AllAllowed()
+		}
+		if result.Err != nil && options.StopOnError {
+			stop(ErrFanOutStopped)
+		}
+		if result.Allowed && options.StopAfterAllowed > 0 && int(atomic.AddInt32(&allowed, 1)) >= options.StopAfterAllowed {
+			stop(ErrFanOutStopped)
+		}
+		out <- result
+	}
+
+	go func() {
+		defer close(out)
+		defer stop(nil)
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, options.concurrency())
+		for i, id := range resourceIds {
+			result := ResourceResult{Index: i, ResourceId: id}
+			authzReq := AuthorizationRequest{
+				Subject:  subject,
+				Actions:  actions,
+				Resource: ResourceInfo{Id: id},
+			}
+			acquired := false
+			select {
+			case slots <- struct{}{}:
+				acquired = true
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				if acquired {
+					<-slots
+				}
+				result.Err = context.Cause(ctx)
+				send(result)
+				continue
+			}
+			wg.Add(1)
+			go func(result ResourceResult, authzReq AuthorizationRequest) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				result.Response, result.Err = client.CheckAccess(ctx, authzReq)
+				if result.Err != nil && ctx.Err() != nil {
+					result.Response, result.Err = nil, context.Cause(ctx)
+				}
+				send(result)
+			}(result, authzReq)
+		}
+		wg.Wait()
+	}()
+
+	return out, nil
+}
+
+// StreamCheckAccessForResources is the package StreamCheckAccessForResources
+// with r
+func (r *remotePDPClient) StreamCheckAccessForResources(ctx context.Context, subject SubjectInfo, actions []ActionInfo, resourceIds []string, options *FanOutOptions) (<-chan ResourceResult, error) {
+	return StreamCheckAccessForResources(ctx, r, subject, actions, resourceIds, options)
+}
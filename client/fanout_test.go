@@ -0,0 +1,420 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+// fanOutTransport answers each CheckAccess request with respond and records
+// the peak number of requests in flight
+type fanOutTransport struct {
+	respond func(authzReq AuthorizationRequest) (int, string)
+	delay   time.Duration
+
+	requests int32
+	inFlight int32
+	mu       sync.Mutex
+	peak     int32
+}
+
+func (f *fanOutTransport) Do(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&f.requests, 1)
+	n := atomic.AddInt32(&f.inFlight, 1)
+	defer atomic.AddInt32(&f.inFlight, -1)
+	f.mu.Lock()
+	if n > f.peak {
+		f.peak = n
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-time.After(f.delay):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	var authzReq AuthorizationRequest
+	if err := json.NewDecoder(req.Body).Decode(&authzReq); err != nil {
+		return nil, err
+	}
+	status, body := f.respond(authzReq)
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// forbiddenBody is the body of a 403 answer of the PDP
+const forbiddenBody = `{"statusCode":403,"message":"forbidden"}`
+
+func TestCheckAccessForSubjects(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	scope := "https://authorization.azure.net/.default"
+	resource := ResourceInfo{Id: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg"}
+	actions := []ActionInfo{{Id: "read"}}
+
+	var subjects []SubjectInfo
+	for i := 0; i < 10; i++ {
+		subjects = append(subjects, SubjectInfo{Attributes: SubjectAttributes{ObjectId: fmt.Sprintf("oid%d", i)}})
+	}
+	// a duplicate is checked once
+	subjects = append(subjects, subjects[0])
+
+	transport := &fanOutTransport{
+		delay: 10 * time.Millisecond,
+		respond: func(authzReq AuthorizationRequest) (int, string) {
+			objectId := authzReq.Subject.Attributes.ObjectId
+			if objectId == "oid3" {
+				return http.StatusForbidden, forbiddenBody
+			}
+			return http.StatusOK, fmt.Sprintf(`{"value":[{"actionId":"read","accessDecision":"Allowed","timeToLiveInMs":60000,"roleAssignment":{"principalId":%q}}]}`, objectId)
+		},
+	}
+	cache := NewMemoryDecisionCache(100)
+	client, err := NewRemotePDPClientWithOptions(endpoint, scope, test.FakeCredential{}, &ClientOptions{
+		ClientOptions: azcore.ClientOptions{Transport: transport},
+		DecisionCache: cache,
+	})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+
+	results, err := client.CheckAccessForSubjects(context.Background(), resource, actions, subjects, &FanOutOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if len(results) != 10 {
+		t.Fatalf("expected 10 results but got %d", len(results))
+	}
+	for objectId, result := range results {
+		if objectId == "oid3" {
+			if result.Err == nil || result.Response != nil {
+				t.Errorf("expected only an error for %s but got %v", objectId, result)
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Errorf("expected error to be 'nil' for %s but got '%v'", objectId, result.Err)
+			continue
+		}
+		if got := result.Response.Value[0].RoleAssignment.PrincipalId; got != objectId {
+			t.Errorf("expected the decision of %s but got the one of %s", objectId, got)
+		}
+	}
+	if transport.requests != 10 {
+		t.Errorf("expected 10 requests but got %d", transport.requests)
+	}
+	if transport.peak > 3 {
+		t.Errorf("expected at most 3 concurrent requests but got %d", transport.peak)
+	}
+	if cache.Len() != 9 {
+		t.Errorf("expected 9 cached decisions but got %d", cache.Len())
+	}
+
+	// the second fan-out only calls the PDP for the subject that failed
+	if _, err := client.CheckAccessForSubjects(context.Background(), resource, actions, subjects, nil); err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if transport.requests != 11 {
+		t.Errorf("expected 11 requests but got %d", transport.requests)
+	}
+}
+
+func TestCheckAccessForSubjectsTelemetry(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	resource := ResourceInfo{Id: "/subscriptions/sub/resourceGroups/rg"}
+	subjects := []SubjectInfo{{Attributes: SubjectAttributes{ObjectId: "oid1"}}, {Attributes: SubjectAttributes{ObjectId: "oid2"}}}
+	transport := &fanOutTransport{respond: func(AuthorizationRequest) (int, string) {
+		return http.StatusOK, `{"value":[{"actionId":"read","accessDecision":"Allowed","timeToLiveInMs":60000}]}`
+	}}
+	spans := tracetest.NewSpanRecorder()
+	client, err := NewRemotePDPClientWithOptions(endpoint, "scope", test.FakeCredential{}, &ClientOptions{
+		ClientOptions:  azcore.ClientOptions{Transport: transport},
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		DecisionCache:  NewMemoryDecisionCache(10),
+	})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.CheckAccessForSubjects(context.Background(), resource, []ActionInfo{{Id: "read"}}, subjects, nil); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+	}
+	if transport.requests != 2 {
+		t.Errorf("expected 2 requests but got %d", transport.requests)
+	}
+	// the cache hits of the second fan-out are traced like single calls
+	var hits int
+	for _, span := range spans.Ended() {
+		for _, attr := range span.Attributes() {
+			if attr.Key == attrCacheHit && attr.Value.AsBool() {
+				hits++
+			}
+		}
+	}
+	if len(spans.Ended()) != 4 || hits != 2 {
+		t.Errorf("expected 4 spans with 2 cache hits but got %d spans with %d cache hits", len(spans.Ended()), hits)
+	}
+}
+
+func TestCheckAccessForSubjectsInvalid(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	client, err := NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{Transport: &fanOutTransport{}})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+	resource := ResourceInfo{Id: "/subscriptions/00000000-0000-0000-0000-000000000000"}
+	actions := []ActionInfo{{Id: "read"}}
+	subjects := []SubjectInfo{{Attributes: SubjectAttributes{ObjectId: "oid"}}}
+
+	for _, tt := range []struct {
+		name     string
+		resource ResourceInfo
+		actions  []ActionInfo
+		subjects []SubjectInfo
+		wantErr  string
+	}{
+		{
+			name:     "fail - no resource id",
+			actions:  actions,
+			subjects: subjects,
+			wantErr:  "need resource id in checking access for subjects",
+		},
+		{
+			name:     "fail - no actions",
+			resource: resource,
+			subjects: subjects,
+			wantErr:  "need actions in checking access for subjects",
+		},
+		{
+			name:     "fail - subject without object id",
+			resource: resource,
+			actions:  actions,
+			subjects: append(subjects, SubjectInfo{}),
+			wantErr:  "need object id of subject 1 in checking access for subjects",
+		},
+		{
+			name:     "fail - duplicate subject with other groups",
+			resource: resource,
+			actions:  actions,
+			subjects: append(subjects, SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid", Groups: []string{"g1"}}}),
+			wantErr:  "subject 1: object id oid duplicates subject 0 with other attributes",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.CheckAccessForSubjects(context.Background(), tt.resource, tt.actions, tt.subjects, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error to be '%s' but got '%v'", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// resourceDecisions answers Allowed for the resources ending with an even
+// digit, NotAllowed for the others and 403 for the resources ending with 9
+func resourceDecisions(authzReq AuthorizationRequest) (int, string) {
+	id := authzReq.Resource.Id
+	switch last := id[len(id)-1]; {
+	case last == '9':
+		return http.StatusForbidden, forbiddenBody
+	case (last-'0')%2 == 0:
+		return http.StatusOK, `{"value":[{"actionId":"read","accessDecision":"Allowed"}]}`
+	default:
+		return http.StatusOK, `{"value":[{"actionId":"read","accessDecision":"NotAllowed"}]}`
+	}
+}
+
+func TestCheckAccessForResources(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	subject := SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid"}}
+	actions := []ActionInfo{{Id: "read"}}
+	var resourceIds []string
+	for i := 0; i < 10; i++ {
+		resourceIds = append(resourceIds, fmt.Sprintf("/subscriptions/sub/resourceGroups/rg%d", i))
+	}
+
+	for _, tt := range []struct {
+		name        string
+		options     *FanOutOptions
+		wantAllowed []string
+		wantStopped bool
+	}{
+		{
+			name:        "pass - every resource is checked",
+			options:     &FanOutOptions{Concurrency: 3},
+			wantAllowed: []string{resourceIds[0], resourceIds[2], resourceIds[4], resourceIds[6], resourceIds[8]},
+		},
+		{
+			name:        "pass - stop on error",
+			options:     &FanOutOptions{Concurrency: 1, StopOnError: true},
+			wantAllowed: []string{resourceIds[0], resourceIds[2], resourceIds[4], resourceIds[6], resourceIds[8]},
+		},
+		{
+			name:        "pass - stop after allowed",
+			options:     &FanOutOptions{Concurrency: 1, StopAfterAllowed: 2},
+			wantAllowed: []string{resourceIds[0], resourceIds[2]},
+			wantStopped: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &fanOutTransport{respond: resourceDecisions, delay: time.Millisecond}
+			client, err := NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{Transport: transport})
+			if err != nil {
+				t.Fatalf("Unable to create a new PDP client: %v", err)
+			}
+
+			results, err := client.CheckAccessForResources(context.Background(), subject, actions, resourceIds, tt.options)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if len(results) != len(resourceIds) {
+				t.Fatalf("expected %d results but got %d", len(resourceIds), len(results))
+			}
+			for i, result := range results {
+				if result.Index != i || result.ResourceId != resourceIds[i] {
+					t.Errorf("expected result %d to be of %s but got %d %s", i, resourceIds[i], result.Index, result.ResourceId)
+				}
+			}
+			if diff := cmp.Diff(tt.wantAllowed, results.AllowedResourceIds()); diff != "" {
+				t.Errorf("incorrect allowed resources: %v", diff)
+			}
+			if tt.wantStopped && !errors.Is(results[len(results)-1].Err, ErrFanOutStopped) {
+				t.Errorf("expected the last resource to be stopped but got '%v'", results[len(results)-1].Err)
+			}
+			if transport.peak > int32(tt.options.concurrency()) {
+				t.Errorf("expected at most %d concurrent requests but got %d", tt.options.concurrency(), transport.peak)
+			}
+		})
+	}
+}
+
+func TestStreamCheckAccessForResources(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	client, err := NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{
+		Transport: &fanOutTransport{respond: resourceDecisions},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+	subject := SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid"}}
+	resourceIds := []string{"/subscriptions/sub/resourceGroups/rg0", "/subscriptions/sub/resourceGroups/rg1", "/subscriptions/sub/resourceGroups/rg9"}
+
+	stream, err := client.StreamCheckAccessForResources(context.Background(), subject, []ActionInfo{{Id: "read"}}, resourceIds, nil)
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	seen := map[int]ResourceResult{}
+	for result := range stream {
+		seen[result.Index] = result
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 results but got %d", len(seen))
+	}
+	if !seen[0].Allowed || seen[1].Allowed || seen[1].Err != nil || seen[2].Err == nil {
+		t.Errorf("unexpected results %v", seen)
+	}
+
+	if _, err := client.StreamCheckAccessForResources(context.Background(), subject, []ActionInfo{{Id: "read"}}, []string{""}, nil); err == nil {
+		t.Error("expected an error for an empty resource id")
+	}
+}
+
+// countingClient is a RemotePDPClient other than the remote client,
+// counting the calls it forwards
+type countingClient struct {
+	RemotePDPClient
+	calls int32
+}
+
+func (c *countingClient) CheckAccess(ctx context.Context, authzReq AuthorizationRequest) (*AuthorizationDecisionResponse, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.RemotePDPClient.CheckAccess(ctx, authzReq)
+}
+
+func TestFanOutOfAnyClient(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	remote, err := NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{
+		Transport: &fanOutTransport{respond: resourceDecisions},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+	client := &countingClient{RemotePDPClient: remote}
+	subject := SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid"}}
+	actions := []ActionInfo{{Id: "read"}}
+	resourceIds := []string{"/subscriptions/sub/resourceGroups/rg0", "/subscriptions/sub/resourceGroups/rg1"}
+
+	results, err := CheckAccessForResources(context.Background(), client, subject, actions, resourceIds, nil)
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if diff := cmp.Diff([]string{resourceIds[0]}, results.AllowedResourceIds()); diff != "" {
+		t.Errorf("incorrect allowed resources: %v", diff)
+	}
+
+	subjects := []SubjectInfo{subject, {Attributes: SubjectAttributes{ObjectId: "oid2"}}}
+	subjectResults, err := CheckAccessForSubjects(context.Background(), client, ResourceInfo{Id: resourceIds[0]}, actions, subjects, nil)
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if len(subjectResults) != 2 {
+		t.Errorf("expected 2 results but got %d", len(subjectResults))
+	}
+	if calls := atomic.LoadInt32(&client.calls); calls != 4 {
+		t.Errorf("expected 4 calls through the client but got %d", calls)
+	}
+
+	if _, err := CheckAccessForResources(context.Background(), nil, subject, actions, resourceIds, nil); err == nil || err.Error() != "need client in checking access for resources" {
+		t.Errorf("expected error to be 'need client in checking access for resources' but got '%v'", err)
+	}
+	if _, err := CheckAccessForSubjects(context.Background(), nil, ResourceInfo{Id: resourceIds[0]}, actions, subjects, nil); err == nil || err.Error() != "need client in checking access for subjects" {
+		t.Errorf("expected error to be 'need client in checking access for subjects' but got '%v'", err)
+	}
+}
+
+func TestCheckAccessForResourcesMissingAction(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	// the PDP decides on read only, as a short or paginated response would
+	client, err := NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{
+		Transport: &fanOutTransport{respond: resourceDecisions},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+	subject := SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid"}}
+
+	results, err := client.CheckAccessForResources(context.Background(), subject, []ActionInfo{{Id: "read"}, {Id: "delete"}}, []string{"/subscriptions/sub/resourceGroups/rg0"}, nil)
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if results[0].Err != nil || results[0].Allowed {
+		t.Errorf("expected the resource not to be allowed but got '%+v'", results[0])
+	}
+	if ids := results.AllowedResourceIds(); len(ids) != 0 {
+		t.Errorf("expected no allowed resources but got '%v'", ids)
+	}
+}
@@ -0,0 +1,97 @@
+package hashchain
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxLineSize is the largest record the verifier accepts
+const maxLineSize = 1024 * 1024
+
+// Problem describes a record that breaks the chain
+type Problem struct {
+	File   string
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: seq %d: %s", p.File, p.Line, p.Seq, p.Reason)
+}
+
+// Verify checks the chain formed by files, in order. It reports gaps in the
+// sequence numbers, broken links to the previous record, records whose hash
+// does not match their content and, when key is set, records with a missing
+// or invalid HMAC. The returned error is only set when a file can't be read.
+func Verify(files []string, key []byte) ([]Problem, error) {
+	var problems []Problem
+	var prev *Record
+
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			report := func(seq uint64, reason string) {
+				problems = append(problems, Problem{File: path, Line: line, Seq: seq, Reason: reason})
+			}
+
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				report(0, fmt.Sprintf("record is not valid JSON: %v", err))
+				continue
+			}
+
+			wantSeq, wantPrevHash := uint64(1), ""
+			if prev != nil {
+				wantSeq, wantPrevHash = prev.Seq+1, prev.Hash
+			}
+			if record.Seq != wantSeq {
+				report(record.Seq, fmt.Sprintf("gap in chain, expected seq %d", wantSeq))
+			} else if record.PrevHash != wantPrevHash {
+				report(record.Seq, "previous hash does not match the previous record")
+			}
+
+			content, err := json.Marshal(chainedContent{Seq: record.Seq, PrevHash: record.PrevHash, Event: record.Event})
+			if err != nil {
+				report(record.Seq, fmt.Sprintf("record can't be encoded: %v", err))
+				continue
+			}
+			sum := sha256.Sum256(content)
+			if hex.EncodeToString(sum[:]) != record.Hash {
+				report(record.Seq, "hash does not match the record content")
+			}
+			if len(key) > 0 && !hmac.Equal([]byte(computeHMAC(key, content)), []byte(record.HMAC)) {
+				report(record.Seq, "HMAC is missing or invalid")
+			}
+
+			prev = &record
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error while reading %s, err: %w", path, err)
+		}
+	}
+
+	return problems, nil
+}
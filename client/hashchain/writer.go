@@ -0,0 +1,347 @@
+package hashchain
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// this asserts that &Writer{} would always implement client.AuditSink
+var _ client.AuditSink = &Writer{}
+
+// fileExtension is the extension of the files written by Writer
+const fileExtension = ".jsonl"
+
+// tornExtension is appended to the name of a file holding only a partial
+// record, when a Writer needs its name for a new file
+const tornExtension = ".torn"
+
+// Record is a line of a hash-chained audit file. Hash is the hex SHA-256 of
+// the JSON encoding of Seq, PrevHash and Event; HMAC is the hex HMAC-SHA256
+// of the same bytes when the writer has a key.
+type Record struct {
+	Seq      uint64          `json:"seq"`
+	PrevHash string          `json:"prevHash"`
+	Event    json.RawMessage `json:"event"`
+	Hash     string          `json:"hash"`
+	HMAC     string          `json:"hmac,omitempty"`
+}
+
+// chainedContent is the part of a Record covered by its hash and HMAC
+type chainedContent struct {
+	Seq      uint64          `json:"seq"`
+	PrevHash string          `json:"prevHash"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// Options configures a Writer
+type Options struct {
+	// Dir is the directory the audit files are written to
+	Dir string
+
+	// Prefix is the name prefix of the audit files, "audit" when empty
+	Prefix string
+
+	// MaxBytes rotates to a new file once the current file reaches this size.
+	// Zero disables size based rotation.
+	MaxBytes int64
+
+	// MaxAge rotates to a new file once the current file is older than this.
+	// Zero disables time based rotation.
+	MaxAge time.Duration
+
+	// Key, if set, adds an HMAC-SHA256 of every record
+	Key []byte
+
+	// Now returns the current time, time.Now when nil
+	Now func() time.Time
+
+	// OnTornTail, if set, is called when a file ends with a partial record,
+	// left by a crash or a failed write. The writer never appends to such
+	// a file: it starts a new one chained from the last valid record.
+	OnTornTail func(problem Problem)
+}
+
+// auditFile is the file a Writer appends to, an *os.File outside of tests
+type auditFile interface {
+	Write(p []byte) (int, error)
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+	Name() string
+}
+
+// Writer is a client.AuditSink appending hash-chained JSON-lines records to
+// rotated files. The chain continues across files and across restarts.
+type Writer struct {
+	options Options
+
+	mu       sync.Mutex
+	file     auditFile
+	size     int64
+	openedAt time.Time
+	seq      uint64
+	lastHash string
+	// torn is the file ending with a partial record, if any
+	torn string
+}
+
+// NewWriter returns a Writer resuming the chain of the latest record in
+// options.Dir, if any
+func NewWriter(options Options) (*Writer, error) {
+	if strings.TrimSpace(options.Dir) == "" {
+		return nil, fmt.Errorf("need a directory in creating hash-chained audit writer")
+	}
+	if options.Prefix == "" {
+		options.Prefix = "audit"
+	}
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+	if err := os.MkdirAll(options.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{options: options}
+	files, err := ListFiles(options.Dir, options.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	// the latest files may have no record yet, the chain resumes from the
+	// last record of the files before them
+	for i := len(files) - 1; i >= 0; i-- {
+		last, problem, err := lastRecord(files[i])
+		if err != nil {
+			return nil, fmt.Errorf("error while resuming audit chain, err: %w", err)
+		}
+		if problem != nil && i == len(files)-1 {
+			w.tear(*problem)
+		}
+		if last != nil {
+			w.seq = last.Seq
+			w.lastHash = last.Hash
+			break
+		}
+	}
+	return w, nil
+}
+
+// WriteAuditEvent appends event to the chain
+func (w *Writer) WriteAuditEvent(ctx context.Context, event client.AuditEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.rotateIfNeeded(); err != nil {
+		return err
+	}
+
+	record, err := newRecord(w.seq+1, w.lastHash, payload, w.options.Key)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	n, err := w.file.Write(line)
+	if err != nil {
+		// the next record can't follow a partial one on the same line
+		if n > 0 {
+			if terr := w.file.Truncate(w.size); terr != nil {
+				w.tear(Problem{File: w.file.Name(), Reason: fmt.Sprintf("partial record after a failed write: %v", terr)})
+				w.file.Close()
+				w.file = nil
+			}
+		}
+		return err
+	}
+	w.size += int64(n)
+	w.seq = record.Seq
+	w.lastHash = record.Hash
+	return w.file.Sync()
+}
+
+// Close closes the current file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotateIfNeeded opens a new file when there is none or the current one
+// reached its size or age limit
+func (w *Writer) rotateIfNeeded() error {
+	now := w.options.Now()
+	if w.file != nil {
+		full := w.options.MaxBytes > 0 && w.size >= w.options.MaxBytes
+		old := w.options.MaxAge > 0 && now.Sub(w.openedAt) >= w.options.MaxAge
+		if !full && !old {
+			return nil
+		}
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+
+	// file names sort in chain order as they carry the first sequence number
+	name := fmt.Sprintf("%s-%020d%s", w.options.Prefix, w.seq+1, fileExtension)
+	path := filepath.Join(w.options.Dir, name)
+	// a torn file with the same first sequence number has no valid record
+	if w.torn == path {
+		if err := os.Rename(path, path+tornExtension); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w.torn = ""
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	w.openedAt = now
+	return nil
+}
+
+// tear records that the file of problem ends with a partial record, so the
+// next record goes to a new file
+func (w *Writer) tear(problem Problem) {
+	w.torn = problem.File
+	if w.options.OnTornTail != nil {
+		w.options.OnTornTail(problem)
+	}
+}
+
+// newRecord returns the record of payload chained to prevHash
+func newRecord(seq uint64, prevHash string, payload json.RawMessage, key []byte) (*Record, error) {
+	content, err := json.Marshal(chainedContent{Seq: seq, PrevHash: prevHash, Event: payload})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	record := &Record{
+		Seq:      seq,
+		PrevHash: prevHash,
+		Event:    payload,
+		Hash:     hex.EncodeToString(sum[:]),
+	}
+	if len(key) > 0 {
+		record.HMAC = computeHMAC(key, content)
+	}
+	return record, nil
+}
+
+// computeHMAC returns the hex HMAC-SHA256 of content
+func computeHMAC(key, content []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ListFiles returns the audit files with prefix in dir, in chain order
+func ListFiles(dir, prefix string) ([]string, error) {
+	if prefix == "" {
+		prefix = "audit"
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix+"-") || !strings.HasSuffix(name, fileExtension) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// lastRecord returns the last valid record of the file at path, nil if it
+// has none, and the problem of its last line when it is a partial record.
+// Invalid records before the last line are an error.
+func lastRecord(path string) (*Record, *Problem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var last *Record
+	var invalid error
+	line, invalidLine := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		if invalid != nil {
+			return nil, nil, fmt.Errorf("%s:%d: record is not valid JSON: %w", path, invalidLine, invalid)
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			invalid, invalidLine = err, line
+			continue
+		}
+		last = &record
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if invalid != nil {
+		return last, &Problem{File: path, Line: invalidLine, Reason: fmt.Sprintf("partial record at the end of the file: %v", invalid)}, nil
+	}
+	// a complete record without its newline would share its line with the next
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() > 0 {
+		end := make([]byte, 1)
+		if _, err := f.ReadAt(end, info.Size()-1); err != nil {
+			return nil, nil, err
+		}
+		if end[0] != '\n' {
+			return last, &Problem{File: path, Line: line, Reason: "partial record at the end of the file: missing newline"}, nil
+		}
+	}
+	return last, nil, nil
+}
@@ -0,0 +1,361 @@
+package hashchain
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+func writeEvents(t *testing.T, w *Writer, actions ...string) {
+	t.Helper()
+	for _, action := range actions {
+		err := w.WriteAuditEvent(context.Background(), client.AuditEvent{
+			SubjectObjectId: "oid",
+			ResourceId:      "/subscriptions/sub",
+			ActionId:        action,
+			AccessDecision:  client.Allowed,
+		})
+		if err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+	}
+}
+
+func TestWriterRotationAndResume(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("secret")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	w, err := NewWriter(Options{Dir: dir, MaxBytes: 1, Key: key, Now: clock})
+	if err != nil {
+		t.Fatalf("Unable to create writer: %v", err)
+	}
+	writeEvents(t, w, "read", "write")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted writer continues the chain, rotating by age
+	w, err = NewWriter(Options{Dir: dir, MaxAge: time.Hour, Key: key, Now: clock})
+	if err != nil {
+		t.Fatalf("Unable to create writer: %v", err)
+	}
+	writeEvents(t, w, "delete", "list")
+	now = now.Add(2 * time.Hour)
+	writeEvents(t, w, "action")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ListFiles(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files but got %d", len(files))
+	}
+	problems, err := Verify(files, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("expected no problems but got %v", problems)
+	}
+}
+
+// lastSeq returns the seq of the last record of the latest file in dir
+func lastSeq(t *testing.T, dir string) uint64 {
+	t.Helper()
+	files, err := ListFiles(dir, "")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("expected files but got %v, %v", files, err)
+	}
+	content, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	var record Record
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	return record.Seq
+}
+
+func TestWriterTornTail(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		tear         func(t *testing.T, dir, file string)
+		wantFiles    int
+		wantProblems int
+	}{
+		{
+			name: "pass - partial last record",
+			tear: func(t *testing.T, dir, file string) {
+				f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, err := f.WriteString(`{"seq":3,"prevHa`); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFiles:    2,
+			wantProblems: 1,
+		},
+		{
+			name: "pass - last record without newline",
+			tear: func(t *testing.T, dir, file string) {
+				content, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, content[:len(content)-1], 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFiles: 2,
+		},
+		{
+			name: "pass - file with only a partial record",
+			tear: func(t *testing.T, dir, file string) {
+				if err := os.WriteFile(filepath.Join(dir, "audit-00000000000000000003.jsonl"), []byte(`{"seq":3,"prevHa`), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantFiles: 2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewWriter(Options{Dir: dir})
+			if err != nil {
+				t.Fatalf("Unable to create writer: %v", err)
+			}
+			writeEvents(t, w, "read", "write")
+			w.Close()
+			files, err := ListFiles(dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.tear(t, dir, files[0])
+
+			var torn []Problem
+			w, err = NewWriter(Options{Dir: dir, OnTornTail: func(problem Problem) { torn = append(torn, problem) }})
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if len(torn) != 1 {
+				t.Errorf("expected the torn tail to be reported but got %v", torn)
+			}
+			writeEvents(t, w, "delete")
+			w.Close()
+
+			files, err = ListFiles(dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != tt.wantFiles {
+				t.Errorf("expected %d files but got %v", tt.wantFiles, files)
+			}
+			if seq := lastSeq(t, dir); seq != 3 {
+				t.Errorf("expected the new record to follow seq 2 but got seq %d", seq)
+			}
+			problems, err := Verify(files, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(problems) != tt.wantProblems {
+				t.Errorf("expected %d problems but got %v", tt.wantProblems, problems)
+			}
+		})
+	}
+}
+
+func TestWriterInvalidRecord(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "audit-00000000000000000001.jsonl"), []byte("not json\n{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWriter(Options{Dir: dir}); err == nil {
+		t.Error("expected an invalid record before the last line to be an error")
+	}
+}
+
+// failingFile writes half of the records it is given and fails
+type failingFile struct {
+	auditFile
+	truncateErr error
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	n, _ := f.auditFile.Write(p[:len(p)/2])
+	return n, errors.New("disk full")
+}
+
+func (f *failingFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.auditFile.Truncate(size)
+}
+
+func TestWriterFailedWrite(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		truncateErr  error
+		wantFiles    int
+		wantTorn     int
+		wantProblems int
+	}{
+		{
+			name:      "pass - partial record is truncated",
+			wantFiles: 1,
+		},
+		{
+			name:         "pass - partial record can't be truncated",
+			truncateErr:  errors.New("read-only file system"),
+			wantFiles:    2,
+			wantTorn:     1,
+			wantProblems: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var torn []Problem
+			w, err := NewWriter(Options{Dir: dir, OnTornTail: func(problem Problem) { torn = append(torn, problem) }})
+			if err != nil {
+				t.Fatalf("Unable to create writer: %v", err)
+			}
+			writeEvents(t, w, "read")
+
+			file := w.file
+			w.file = &failingFile{auditFile: file, truncateErr: tt.truncateErr}
+			if err := w.WriteAuditEvent(context.Background(), client.AuditEvent{ActionId: "write"}); err == nil {
+				t.Fatal("expected the write to fail")
+			}
+			if w.file != nil {
+				w.file = file
+			}
+			writeEvents(t, w, "delete")
+			w.Close()
+
+			files, err := ListFiles(dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != tt.wantFiles {
+				t.Errorf("expected %d files but got %v", tt.wantFiles, files)
+			}
+			if len(torn) != tt.wantTorn {
+				t.Errorf("expected %d torn files but got %v", tt.wantTorn, torn)
+			}
+			if seq := lastSeq(t, dir); seq != 2 {
+				t.Errorf("expected the next record to follow seq 1 but got seq %d", seq)
+			}
+			problems, err := Verify(files, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(problems) != tt.wantProblems {
+				t.Errorf("expected %d problems but got %v", tt.wantProblems, problems)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		key        []byte
+		tamper     func(lines []string) []string
+		wantReason string
+	}{
+		{
+			name:   "pass - untouched chain",
+			tamper: func(lines []string) []string { return lines },
+		},
+		{
+			name: "fail - modified entry",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"Allowed"`, `"Denied"`, 1)
+				return lines
+			},
+			wantReason: "hash does not match the record content",
+		},
+		{
+			name: "fail - removed entry",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantReason: "gap in chain, expected seq 2",
+		},
+		{
+			name: "fail - reordered entries",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			wantReason: "gap in chain, expected seq 2",
+		},
+		{
+			name:       "fail - wrong HMAC key",
+			key:        []byte("other"),
+			tamper:     func(lines []string) []string { return lines },
+			wantReason: "HMAC is missing or invalid",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewWriter(Options{Dir: dir, Key: []byte("secret")})
+			if err != nil {
+				t.Fatalf("Unable to create writer: %v", err)
+			}
+			writeEvents(t, w, "read", "write", "delete")
+			w.Close()
+
+			files, err := ListFiles(dir, "")
+			if err != nil || len(files) != 1 {
+				t.Fatalf("expected 1 file but got %v, %v", files, err)
+			}
+			content, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(strings.Split(strings.TrimSpace(string(content)), "\n"))
+			if err := os.WriteFile(files[0], []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			key := []byte("secret")
+			if tt.key != nil {
+				key = tt.key
+			}
+			problems, err := Verify(files, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantReason == "" {
+				if len(problems) != 0 {
+					t.Errorf("expected no problems but got %v", problems)
+				}
+				return
+			}
+			if len(problems) == 0 || problems[0].Reason != tt.wantReason {
+				t.Errorf("expected first problem to be %q but got %v", tt.wantReason, problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/token"
+)
+
+// entraIssuer matches the v1 and v2 issuers of Entra ID tenants
+var entraIssuer = regexp.MustCompile(`^https://(sts\.windows\.net/([0-9a-fA-F-]+)/|login\.microsoftonline\.com/([0-9a-fA-F-]+)/v2\.0)$`)
+
+// TokenAnomalyCode identifies an anomaly found while inspecting a token
+type TokenAnomalyCode string
+
+// TokenAnomalyCode possible values
+const (
+	AnomalyMissingObjectId      TokenAnomalyCode = "MissingObjectId"
+	AnomalyGroupsAndClaimNames  TokenAnomalyCode = "GroupsAndClaimNames"
+	AnomalyGroupOverage         TokenAnomalyCode = "GroupOverage"
+	AnomalyGuest                TokenAnomalyCode = "Guest"
+	AnomalyExpired              TokenAnomalyCode = "Expired"
+	AnomalyNotYetValid          TokenAnomalyCode = "NotYetValid"
+	AnomalyUnknownIssuer        TokenAnomalyCode = "UnknownIssuer"
+	AnomalyIssuerTenantMismatch TokenAnomalyCode = "IssuerTenantMismatch"
+)
+
+// TokenAnomaly explains why a token may be evaluated as an unexpected subject
+type TokenAnomaly struct {
+	Code    TokenAnomalyCode `json:"code"`
+	Message string           `json:"message"`
+}
+
+// TokenInspection is what CreateAuthorizationRequest derives from a token
+type TokenInspection struct {
+	// Subject is the exact SubjectAttributes CreateAuthorizationRequest sends
+	Subject SubjectAttributes `json:"subject"`
+
+	ObjectId         string     `json:"objectId,omitempty"`
+	TenantId         string     `json:"tenantId,omitempty"`
+	ApplicationId    string     `json:"applicationId,omitempty"`
+	Issuer           string     `json:"issuer,omitempty"`
+	IdentityProvider string     `json:"identityProvider,omitempty"`
+	AltSecId         string     `json:"altSecId,omitempty"`
+	Audience         []string   `json:"audience,omitempty"`
+	Version          string     `json:"version,omitempty"`
+	IssuedAt         *time.Time `json:"issuedAt,omitempty"`
+	NotBefore        *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	GroupCount       int        `json:"groupCount"`
+	HasClaimNames    bool       `json:"hasClaimNames"`
+
+	Anomalies []TokenAnomaly `json:"anomalies,omitempty"`
+}
+
+// InspectOptions configures InspectToken
+type InspectOptions struct {
+	// TrustedIssuers are the accepted issuers. Entra ID v1 and v2 issuers are
+	// accepted when empty.
+	TrustedIssuers []string
+
+	// Now returns the current time, time.Now when nil
+	Now func() time.Time
+}
+
+// InspectToken decodes jwtToken the way CreateAuthorizationRequest does,
+// without validating its signature, and reports the subject it yields along
+// with the anomalies that commonly explain unexpected decisions.
+func InspectToken(jwtToken string, options *InspectOptions) (*TokenInspection, error) {
+	if strings.TrimSpace(jwtToken) == "" {
+		return nil, fmt.Errorf("need token in inspecting token")
+	}
+	if options == nil {
+		options = &InspectOptions{}
+	}
+	now := time.Now
+	if options.Now != nil {
+		now = options.Now
+	}
+
+	tokenClaims, err := token.ExtractClaims(jwtToken)
+	if err != nil {
+		return nil, fmt.Errorf("error while parse the token, err: %w", err)
+	}
+
+	inspection := &TokenInspection{
+		Subject:          subjectAttributesFromClaims(tokenClaims),
+		ObjectId:         tokenClaims.ObjectId,
+		TenantId:         tokenClaims.TenantId,
+		ApplicationId:    tokenClaims.AppId,
+		Issuer:           tokenClaims.Issuer,
+		IdentityProvider: tokenClaims.IdentityProvider,
+		AltSecId:         tokenClaims.AltSecId,
+		Audience:         tokenClaims.Audience,
+		Version:          tokenClaims.Version,
+		GroupCount:       len(tokenClaims.Groups),
+		HasClaimNames:    tokenClaims.ClaimNames != nil,
+	}
+	if inspection.ApplicationId == "" {
+		inspection.ApplicationId = tokenClaims.AuthorizedParty
+	}
+	if tokenClaims.IssuedAt != nil {
+		inspection.IssuedAt = &tokenClaims.IssuedAt.Time
+	}
+	if tokenClaims.NotBefore != nil {
+		inspection.NotBefore = &tokenClaims.NotBefore.Time
+	}
+	if tokenClaims.ExpiresAt != nil {
+		inspection.ExpiresAt = &tokenClaims.ExpiresAt.Time
+	}
+
+	anomaly := func(code TokenAnomalyCode, format string, args ...interface{}) {
+		inspection.Anomalies = append(inspection.Anomalies, TokenAnomaly{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if tokenClaims.ObjectId == "" {
+		anomaly(AnomalyMissingObjectId, "the token has no oid claim, the request is sent with an empty ObjectId")
+	}
+	switch {
+	case tokenClaims.ClaimNames != nil && len(tokenClaims.Groups) > 0:
+		anomaly(AnomalyGroupsAndClaimNames, "the token has both groups and _claim_names claims, neither the groups nor the group expansion hint is sent")
+	case tokenClaims.ClaimNames != nil:
+		anomaly(AnomalyGroupOverage, "the token has a group overage claim, the PDP expands the groups of the subject itself")
+	}
+	if tokenClaims.AltSecId != "" {
+		anomaly(AnomalyGuest, "the token has an altsecid claim, the subject is a guest from %s evaluated as object %s of tenant %s",
+			orUnknown(tokenClaims.IdentityProvider), orUnknown(tokenClaims.ObjectId), orUnknown(tokenClaims.TenantId))
+	}
+
+	t := now()
+	if tokenClaims.ExpiresAt != nil && !t.Before(tokenClaims.ExpiresAt.Time) {
+		anomaly(AnomalyExpired, "the token expired at %s", tokenClaims.ExpiresAt.Time.UTC().Format(time.RFC3339))
+	}
+	if tokenClaims.NotBefore != nil && t.Before(tokenClaims.NotBefore.Time) {
+		anomaly(AnomalyNotYetValid, "the token is not valid before %s", tokenClaims.NotBefore.Time.UTC().Format(time.RFC3339))
+	}
+
+	if len(options.TrustedIssuers) > 0 {
+		trusted := false
+		for _, issuer := range options.TrustedIssuers {
+			trusted = trusted || issuer == tokenClaims.Issuer
+		}
+		if !trusted {
+			anomaly(AnomalyUnknownIssuer, "the issuer %s is not trusted", orUnknown(tokenClaims.Issuer))
+		}
+	} else if match := entraIssuer.FindStringSubmatch(tokenClaims.Issuer); match == nil {
+		anomaly(AnomalyUnknownIssuer, "the issuer %s is not an Entra ID issuer", orUnknown(tokenClaims.Issuer))
+	} else if issuerTenant := match[2] + match[3]; tokenClaims.TenantId != "" && !strings.EqualFold(issuerTenant, tokenClaims.TenantId) {
+		anomaly(AnomalyIssuerTenantMismatch, "the issuer is tenant %s but the tid claim is %s", issuerTenant, tokenClaims.TenantId)
+	}
+
+	return inspection, nil
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "<unknown>"
+	}
+	return s
+}
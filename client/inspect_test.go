@@ -0,0 +1,127 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+func TestInspectToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tenant := "72f988bf-86f1-41af-91ab-2d7cd011db47"
+	valid := jwt.RegisteredClaims{
+		Issuer:    "https://sts.windows.net/" + tenant + "/",
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		NotBefore: jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+
+	for _, tt := range []struct {
+		name          string
+		claims        internal.Custom
+		options       InspectOptions
+		wantSubject   SubjectAttributes
+		wantAnomalies []TokenAnomalyCode
+	}{
+		{
+			name:        "pass - no anomalies",
+			claims:      internal.Custom{ObjectId: "oid", TenantId: tenant, Groups: []string{"g1"}, RegisteredClaims: valid},
+			wantSubject: SubjectAttributes{ObjectId: "oid", TenantId: tenant, Groups: []string{"g1"}},
+		},
+		{
+			name:          "missing oid",
+			claims:        internal.Custom{TenantId: tenant, RegisteredClaims: valid},
+			wantSubject:   SubjectAttributes{TenantId: tenant},
+			wantAnomalies: []TokenAnomalyCode{AnomalyMissingObjectId},
+		},
+		{
+			name:          "groups and claim names both present",
+			claims:        internal.Custom{ObjectId: "oid", TenantId: tenant, Groups: []string{"g1"}, ClaimNames: map[string]interface{}{"groups": "src1"}, RegisteredClaims: valid},
+			wantSubject:   SubjectAttributes{ObjectId: "oid", TenantId: tenant},
+			wantAnomalies: []TokenAnomalyCode{AnomalyGroupsAndClaimNames},
+		},
+		{
+			name:          "group overage",
+			claims:        internal.Custom{ObjectId: "oid", TenantId: tenant, ClaimNames: map[string]interface{}{"groups": "src1"}, RegisteredClaims: valid},
+			wantSubject:   SubjectAttributes{ObjectId: "oid", TenantId: tenant, ClaimName: GroupExpansion},
+			wantAnomalies: []TokenAnomalyCode{AnomalyGroupOverage},
+		},
+		{
+			name:          "guest with altsecid",
+			claims:        internal.Custom{ObjectId: "oid", TenantId: tenant, AltSecId: "5::10033FFF", IdentityProvider: "live.com", RegisteredClaims: valid},
+			wantSubject:   SubjectAttributes{ObjectId: "oid", TenantId: tenant},
+			wantAnomalies: []TokenAnomalyCode{AnomalyGuest},
+		},
+		{
+			name: "expired and unknown issuer",
+			claims: internal.Custom{ObjectId: "oid", TenantId: tenant, RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "https://issuer.example.com",
+				ExpiresAt: jwt.NewNumericDate(now.Add(-time.Minute)),
+			}},
+			wantSubject:   SubjectAttributes{ObjectId: "oid", TenantId: tenant},
+			wantAnomalies: []TokenAnomalyCode{AnomalyExpired, AnomalyUnknownIssuer},
+		},
+		{
+			name:          "issuer of another tenant",
+			claims:        internal.Custom{ObjectId: "oid", TenantId: "00000000-0000-0000-0000-000000000000", RegisteredClaims: valid},
+			wantSubject:   SubjectAttributes{ObjectId: "oid", TenantId: "00000000-0000-0000-0000-000000000000"},
+			wantAnomalies: []TokenAnomalyCode{AnomalyIssuerTenantMismatch},
+		},
+		{
+			name:          "issuer not in the trusted issuers",
+			claims:        internal.Custom{ObjectId: "oid", TenantId: tenant, RegisteredClaims: valid},
+			options:       InspectOptions{TrustedIssuers: []string{"https://login.microsoftonline.com/" + tenant + "/v2.0"}},
+			wantSubject:   SubjectAttributes{ObjectId: "oid", TenantId: tenant},
+			wantAnomalies: []TokenAnomalyCode{AnomalyUnknownIssuer},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := tt.claims
+			testtoken, err := test.CreateTestToken(claims.ObjectId, &claims)
+			if err != nil {
+				t.Fatalf("Error creating test token: %v", err)
+			}
+			tt.options.Now = func() time.Time { return now }
+
+			inspection, err := InspectToken(testtoken, &tt.options)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(tt.wantSubject, inspection.Subject); diff != "" {
+				t.Errorf("incorrect subject: %v", diff)
+			}
+			var codes []TokenAnomalyCode
+			for _, a := range inspection.Anomalies {
+				codes = append(codes, a.Code)
+			}
+			if diff := cmp.Diff(tt.wantAnomalies, codes); diff != "" {
+				t.Errorf("incorrect anomalies: %v", diff)
+			}
+
+			// the subject is the one CreateAuthorizationRequest sends
+			authzReq, err := createAuthorizationRequest("/subscriptions/sub", nil, testtoken)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(authzReq.Subject.Attributes, inspection.Subject); diff != "" {
+				t.Errorf("subject differs from CreateAuthorizationRequest: %v", diff)
+			}
+		})
+	}
+}
+
+func TestInspectTokenInvalid(t *testing.T) {
+	for _, jwtToken := range []string{"", "invalid"} {
+		if _, err := InspectToken(jwtToken, nil); err == nil {
+			t.Errorf("expected error to be 'non-nil' for token %q", jwtToken)
+		}
+	}
+}
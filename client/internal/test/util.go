@@ -4,12 +4,16 @@ package test
 // Licensed under the Apache License 2.0.
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 
@@ -18,6 +22,14 @@ import (
 
 type mockTransport struct {
 	statusCode int
+	body       string
+}
+
+// FakeCredential is an azcore.TokenCredential returning a static token
+type FakeCredential struct{}
+
+func (FakeCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fake-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
 }
 
 func CreateTestToken(oid string, fakeClaims *internal.Custom) (string, error) {
@@ -60,9 +72,20 @@ func (m *mockTransport) Do(req *http.Request) (*http.Response, error) {
 	return &http.Response{
 		StatusCode: m.statusCode,
 		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(m.body)),
+		Request:    req,
 	}, nil
 }
 
+// CreateTransport returns a transport that answers every request with
+// returnedHttpCode and body
+func CreateTransport(returnedHttpCode int, body string) policy.Transporter {
+	return &mockTransport{
+		statusCode: returnedHttpCode,
+		body:       body,
+	}
+}
+
 func CreatePipelineWithServer(returnedHttpCode int) runtime.Pipeline {
 	return runtime.NewPipeline(
 		"remotepdpclient_test",
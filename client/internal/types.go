@@ -7,8 +7,14 @@ import (
 )
 
 type Custom struct {
-	ObjectId   string                 `json:"oid"`
-	ClaimNames map[string]interface{} `json:"_claim_names"`
-	Groups     []string               `json:"groups"`
+	ObjectId         string                 `json:"oid"`
+	ClaimNames       map[string]interface{} `json:"_claim_names"`
+	Groups           []string               `json:"groups"`
+	TenantId         string                 `json:"tid,omitempty"`
+	AltSecId         string                 `json:"altsecid,omitempty"`
+	IdentityProvider string                 `json:"idp,omitempty"`
+	AppId            string                 `json:"appid,omitempty"`
+	AuthorizedParty  string                 `json:"azp,omitempty"`
+	Version          string                 `json:"ver,omitempty"`
 	jwt.RegisteredClaims
 }
@@ -0,0 +1,61 @@
+package kubeauthz
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+// The types below are the subset of the authorization.k8s.io
+// SubjectAccessReview API the webhook reads and writes, so the SDK does not
+// depend on the Kubernetes client libraries.
+
+// API versions of SubjectAccessReview the webhook accepts
+const (
+	APIVersionV1      = "authorization.k8s.io/v1"
+	APIVersionV1beta1 = "authorization.k8s.io/v1beta1"
+)
+
+// kindSubjectAccessReview is the kind of SubjectAccessReview objects
+const kindSubjectAccessReview = "SubjectAccessReview"
+
+// SubjectAccessReview asks whether a user can perform a request
+type SubjectAccessReview struct {
+	APIVersion string                    `json:"apiVersion"`
+	Kind       string                    `json:"kind"`
+	Spec       SubjectAccessReviewSpec   `json:"spec"`
+	Status     SubjectAccessReviewStatus `json:"status"`
+}
+
+// SubjectAccessReviewSpec is the request to authorize
+type SubjectAccessReviewSpec struct {
+	ResourceAttributes    *ResourceAttributes    `json:"resourceAttributes,omitempty"`
+	NonResourceAttributes *NonResourceAttributes `json:"nonResourceAttributes,omitempty"`
+	User                  string                 `json:"user,omitempty"`
+	Groups                []string               `json:"groups,omitempty"`
+	Extra                 map[string][]string    `json:"extra,omitempty"`
+	UID                   string                 `json:"uid,omitempty"`
+}
+
+// ResourceAttributes describe a request on a resource of the API server
+type ResourceAttributes struct {
+	Namespace   string `json:"namespace,omitempty"`
+	Verb        string `json:"verb,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// NonResourceAttributes describe a request on a non-resource path
+type NonResourceAttributes struct {
+	Path string `json:"path,omitempty"`
+	Verb string `json:"verb,omitempty"`
+}
+
+// SubjectAccessReviewStatus is the decision of the webhook. A review neither
+// allowed nor denied lets the other authorizers of the cluster decide.
+type SubjectAccessReviewStatus struct {
+	Allowed         bool   `json:"allowed"`
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+}
@@ -0,0 +1,311 @@
+package kubeauthz
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+// Package kubeauthz is a Kubernetes authorization webhook delegating the
+// SubjectAccessReviews of a cluster to Azure RBAC through CheckAccess.
+//
+// A review of the verb get on the pods of the namespace web is checked as
+// the data action Microsoft.Kubernetes/connectedClusters/pods/read on the
+// scope {cluster}/namespaces/web with the default templates.
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// Placeholders of the templates of Options
+const (
+	PlaceholderCluster     = "{cluster}"
+	PlaceholderNamespace   = "{namespace}"
+	PlaceholderGroup       = "{group}"
+	PlaceholderResource    = "{resource}"
+	PlaceholderSubresource = "{subresource}"
+	PlaceholderVerb        = "{verb}"
+)
+
+// Default templates, those of Azure Arc-enabled Kubernetes
+const (
+	DefaultActionTemplate         = "Microsoft.Kubernetes/connectedClusters/{group}/{resource}/{subresource}/{verb}"
+	DefaultNamespaceScopeTemplate = "{cluster}/namespaces/{namespace}"
+	DefaultClusterScopeTemplate   = "{cluster}"
+)
+
+// Default time to live of the cached reviews
+const (
+	DefaultAllowedTTL = 5 * time.Minute
+	DefaultDeniedTTL  = 30 * time.Second
+)
+
+// DefaultVerbs maps the verbs of the API server to the verbs of actions
+var DefaultVerbs = map[string]string{
+	"get":              "read",
+	"list":             "read",
+	"watch":            "read",
+	"create":           "write",
+	"update":           "write",
+	"patch":            "write",
+	"delete":           "delete",
+	"deletecollection": "delete",
+}
+
+// DefaultSubresourceVerbs maps the connect subresources, whose verbs depend
+// on the protocol of the client rather than on what it does, to the verbs
+// of actions
+var DefaultSubresourceVerbs = map[string]string{
+	"exec":        "action",
+	"attach":      "action",
+	"portforward": "action",
+	"proxy":       "action",
+}
+
+// Options configures the webhook
+type Options struct {
+	// Client decides the reviews
+	Client client.RemotePDPClient
+	// ClusterResourceId is the ARM ID of the cluster
+	ClusterResourceId string
+
+	// ActionTemplate builds the action of a review, DefaultActionTemplate
+	// when empty. Empty placeholders are dropped with their separator.
+	ActionTemplate string
+	// NamespaceScopeTemplate builds the scope of namespaced reviews,
+	// DefaultNamespaceScopeTemplate when empty
+	NamespaceScopeTemplate string
+	// ClusterScopeTemplate builds the scope of cluster-wide reviews,
+	// DefaultClusterScopeTemplate when empty
+	ClusterScopeTemplate string
+	// Verbs maps the verbs of the API server to action verbs, DefaultVerbs
+	// when nil. Unmapped verbs become "action".
+	Verbs map[string]string
+	// SubresourceVerbs maps subresources to action verbs regardless of the
+	// verb of the review, DefaultSubresourceVerbs when nil
+	SubresourceVerbs map[string]string
+
+	// Subject derives the subject of a review. The object ID is read from
+	// the "oid" extra of the review, or its user when absent, and the groups
+	// are the review groups without the system: ones when nil. It isn't
+	// called for the system: users, which get no opinion.
+	Subject func(spec SubjectAccessReviewSpec) (client.SubjectAttributes, error)
+
+	// Cache stores the decisions of the reviews, a memory cache of 10000
+	// entries when nil
+	Cache client.DecisionCache
+	// AllowedTTL and DeniedTTL are how long allowed and not allowed reviews
+	// are cached, DefaultAllowedTTL and DefaultDeniedTTL when zero. Negative
+	// values disable caching.
+	AllowedTTL time.Duration
+	DeniedTTL  time.Duration
+}
+
+// webhook is the http.Handler of SubjectAccessReviews
+type webhook struct {
+	options Options
+}
+
+var _ http.Handler = &webhook{}
+
+// NewWebhook returns the http.Handler answering the SubjectAccessReviews of
+// the API server with the decisions of options.Client
+func NewWebhook(options Options) (*webhook, error) {
+	if options.Client == nil {
+		return nil, fmt.Errorf("need client in creating webhook")
+	}
+	if strings.TrimSpace(options.ClusterResourceId) == "" {
+		return nil, fmt.Errorf("need cluster resource id in creating webhook")
+	}
+	if options.ActionTemplate == "" {
+		options.ActionTemplate = DefaultActionTemplate
+	}
+	if options.NamespaceScopeTemplate == "" {
+		options.NamespaceScopeTemplate = DefaultNamespaceScopeTemplate
+	}
+	if options.ClusterScopeTemplate == "" {
+		options.ClusterScopeTemplate = DefaultClusterScopeTemplate
+	}
+	if options.Verbs == nil {
+		options.Verbs = DefaultVerbs
+	}
+	if options.SubresourceVerbs == nil {
+		options.SubresourceVerbs = DefaultSubresourceVerbs
+	}
+	if options.Subject == nil {
+		options.Subject = defaultSubject
+	}
+	if options.Cache == nil {
+		options.Cache = client.NewMemoryDecisionCache(10000)
+	}
+	if options.AllowedTTL == 0 {
+		options.AllowedTTL = DefaultAllowedTTL
+	}
+	if options.DeniedTTL == 0 {
+		options.DeniedTTL = DefaultDeniedTTL
+	}
+	return &webhook{options: options}, nil
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "need POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var review SubjectAccessReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("error while parsing the SubjectAccessReview, err: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Kind != kindSubjectAccessReview || (review.APIVersion != APIVersionV1 && review.APIVersion != APIVersionV1beta1) {
+		http.Error(w, fmt.Sprintf("kind: %s %s is not valid, need a %s of %s or %s", review.APIVersion, review.Kind, kindSubjectAccessReview, APIVersionV1, APIVersionV1beta1), http.StatusBadRequest)
+		return
+	}
+
+	review.Status = h.Review(r.Context(), review.Spec)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(review)
+}
+
+// Review decides spec. The Kubernetes system: users, such as service
+// accounts and nodes, have no Azure identity: their reviews get no opinion
+// without calling the PDP, and are left to the other authorizers.
+func (h *webhook) Review(ctx context.Context, spec SubjectAccessReviewSpec) SubjectAccessReviewStatus {
+	if spec.ResourceAttributes == nil {
+		return SubjectAccessReviewStatus{Reason: "non-resource requests are not authorized by Azure RBAC"}
+	}
+	if strings.HasPrefix(spec.User, systemPrefix) {
+		return SubjectAccessReviewStatus{Reason: fmt.Sprintf("%s is a Kubernetes system user, not authorized by Azure RBAC", spec.User)}
+	}
+	authzReq, err := h.AuthorizationRequest(spec)
+	if err != nil {
+		return SubjectAccessReviewStatus{EvaluationError: err.Error()}
+	}
+
+	key, err := cacheKey(authzReq)
+	if err != nil {
+		return SubjectAccessReviewStatus{EvaluationError: err.Error()}
+	}
+	res, cached := h.options.Cache.Get(key)
+	if !cached {
+		if res, err = h.options.Client.CheckAccess(ctx, *authzReq); err != nil {
+			return SubjectAccessReviewStatus{EvaluationError: fmt.Sprintf("error while checking access, err: %v", err)}
+		}
+	}
+
+	result := client.NewDecisionResult(authzReq.Actions, res)
+	action := authzReq.Actions[0].Id
+	var status SubjectAccessReviewStatus
+	var ttl time.Duration
+	switch {
+	case result.AllAllowed():
+		status = SubjectAccessReviewStatus{Allowed: true, Reason: fmt.Sprintf("%s is allowed on %s", action, authzReq.Resource.Id)}
+		ttl = h.options.AllowedTTL
+	case result.AnyDenied():
+		status = SubjectAccessReviewStatus{Denied: true, Reason: fmt.Sprintf("%s is denied on %s by a deny assignment", action, authzReq.Resource.Id)}
+		ttl = h.options.DeniedTTL
+	default:
+		status = SubjectAccessReviewStatus{Reason: fmt.Sprintf("%s is not allowed on %s", action, authzReq.Resource.Id)}
+		ttl = h.options.DeniedTTL
+	}
+	if !cached && ttl > 0 {
+		h.options.Cache.Set(key, res, ttl)
+	}
+	return status
+}
+
+// AuthorizationRequest maps the resource attributes of spec to an
+// AuthorizationRequest with a data action
+func (h *webhook) AuthorizationRequest(spec SubjectAccessReviewSpec) (*client.AuthorizationRequest, error) {
+	attrs := spec.ResourceAttributes
+	if attrs == nil {
+		return nil, fmt.Errorf("need resource attributes in creating AuthorizationRequest")
+	}
+	if attrs.Verb == "" || attrs.Resource == "" {
+		return nil, fmt.Errorf("need verb and resource in creating AuthorizationRequest")
+	}
+	subject, err := h.options.Subject(spec)
+	if err != nil {
+		return nil, err
+	}
+	if subject.ObjectId == "" {
+		return nil, fmt.Errorf("need subject object id in creating AuthorizationRequest")
+	}
+
+	verb, ok := h.options.SubresourceVerbs[attrs.Subresource]
+	if !ok || attrs.Subresource == "" {
+		if verb, ok = h.options.Verbs[attrs.Verb]; !ok {
+			verb = "action"
+		}
+	}
+	values := map[string]string{
+		PlaceholderCluster:     strings.TrimSuffix(h.options.ClusterResourceId, "/"),
+		PlaceholderNamespace:   attrs.Namespace,
+		PlaceholderGroup:       attrs.Group,
+		PlaceholderResource:    attrs.Resource,
+		PlaceholderSubresource: attrs.Subresource,
+		PlaceholderVerb:        verb,
+	}
+	scopeTemplate := h.options.ClusterScopeTemplate
+	if attrs.Namespace != "" {
+		scopeTemplate = h.options.NamespaceScopeTemplate
+	}
+
+	return &client.AuthorizationRequest{
+		Subject: client.SubjectInfo{Attributes: subject},
+		Actions: []client.ActionInfo{{
+			Id:           expand(h.options.ActionTemplate, values),
+			IsDataAction: true,
+		}},
+		Resource: client.ResourceInfo{Id: expand(scopeTemplate, values)},
+	}, nil
+}
+
+// systemPrefix is the prefix of the Kubernetes system users and groups
+const systemPrefix = "system:"
+
+// defaultSubject reads the object ID from the "oid" extra or the user, and
+// keeps the groups that are not Kubernetes system groups
+func defaultSubject(spec SubjectAccessReviewSpec) (client.SubjectAttributes, error) {
+	subject := client.SubjectAttributes{ObjectId: spec.User}
+	if oids := spec.Extra["oid"]; len(oids) > 0 && oids[0] != "" {
+		subject.ObjectId = oids[0]
+	}
+	for _, group := range spec.Groups {
+		if !strings.HasPrefix(group, systemPrefix) {
+			subject.Groups = append(subject.Groups, group)
+		}
+	}
+	return subject, nil
+}
+
+// expand replaces the placeholders of template with values, dropping the
+// path segments of empty values
+func expand(template string, values map[string]string) string {
+	segments := strings.Split(template, "/")
+	kept := segments[:0]
+	for _, segment := range segments {
+		for placeholder, value := range values {
+			segment = strings.ReplaceAll(segment, placeholder, value)
+		}
+		if segment != "" || len(kept) == 0 {
+			kept = append(kept, segment)
+		}
+	}
+	return strings.Join(kept, "/")
+}
+
+// cacheKey returns the decision cache key of authzReq
+func cacheKey(authzReq *client.AuthorizationRequest) (string, error) {
+	payload, err := json.Marshal(authzReq)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
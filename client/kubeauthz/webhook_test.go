@@ -0,0 +1,293 @@
+package kubeauthz
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/fakepdp"
+)
+
+const cluster = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Kubernetes/connectedClusters/arc"
+
+func review(t *testing.T, h http.Handler, file string) (int, SubjectAccessReview) {
+	t.Helper()
+	body, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(string(body))))
+	var out SubjectAccessReview
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+	}
+	return w.Code, out
+}
+
+func TestWebhook(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		file        string
+		decisions   map[string]client.AccessDecision
+		err         error
+		wantAction  string
+		wantScope   string
+		wantSubject client.SubjectAttributes
+		wantStatus  SubjectAccessReviewStatus
+	}{
+		{
+			name:        "pass - namespaced core resource",
+			file:        "testdata/pod-get.json",
+			decisions:   map[string]client.AccessDecision{"Microsoft.Kubernetes/connectedClusters/pods/read": client.Allowed},
+			wantAction:  "Microsoft.Kubernetes/connectedClusters/pods/read",
+			wantScope:   cluster + "/namespaces/kittensandponies",
+			wantSubject: client.SubjectAttributes{ObjectId: "11111111-1111-1111-1111-111111111111", Groups: []string{"00000000-0000-0000-0000-0000000000a1"}},
+			wantStatus:  SubjectAccessReviewStatus{Allowed: true},
+		},
+		{
+			name:        "pass - denied by a deny assignment",
+			file:        "testdata/deployment-delete.json",
+			decisions:   map[string]client.AccessDecision{"Microsoft.Kubernetes/connectedClusters/apps/deployments/delete": client.Denied},
+			wantAction:  "Microsoft.Kubernetes/connectedClusters/apps/deployments/delete",
+			wantScope:   cluster + "/namespaces/prod",
+			wantSubject: client.SubjectAttributes{ObjectId: "22222222-2222-2222-2222-222222222222"},
+			wantStatus:  SubjectAccessReviewStatus{Denied: true},
+		},
+		{
+			name:        "pass - cluster scoped v1beta1 not allowed",
+			file:        "testdata/nodes-list.json",
+			wantAction:  "Microsoft.Kubernetes/connectedClusters/nodes/read",
+			wantScope:   cluster,
+			wantSubject: client.SubjectAttributes{ObjectId: "33333333-3333-3333-3333-333333333333"},
+			wantStatus:  SubjectAccessReviewStatus{},
+		},
+		{
+			name:        "pass - exec subresource",
+			file:        "testdata/pod-exec.json",
+			decisions:   map[string]client.AccessDecision{"Microsoft.Kubernetes/connectedClusters/pods/exec/action": client.Allowed},
+			wantAction:  "Microsoft.Kubernetes/connectedClusters/pods/exec/action",
+			wantScope:   cluster + "/namespaces/default",
+			wantSubject: client.SubjectAttributes{ObjectId: "44444444-4444-4444-4444-444444444444"},
+			wantStatus:  SubjectAccessReviewStatus{Allowed: true},
+		},
+		{
+			name:        "pass - connect subresource over websocket",
+			file:        "testdata/pod-portforward.json",
+			decisions:   map[string]client.AccessDecision{"Microsoft.Kubernetes/connectedClusters/pods/portforward/action": client.Allowed},
+			wantAction:  "Microsoft.Kubernetes/connectedClusters/pods/portforward/action",
+			wantScope:   cluster + "/namespaces/default",
+			wantSubject: client.SubjectAttributes{ObjectId: "44444444-4444-4444-4444-444444444444"},
+			wantStatus:  SubjectAccessReviewStatus{Allowed: true},
+		},
+		{
+			name:       "pass - non-resource request has no opinion",
+			file:       "testdata/healthz.json",
+			wantStatus: SubjectAccessReviewStatus{},
+		},
+		{
+			name:       "pass - service account has no opinion",
+			file:       "testdata/serviceaccount-get.json",
+			decisions:  map[string]client.AccessDecision{"Microsoft.Kubernetes/connectedClusters/configmaps/read": client.Allowed},
+			wantStatus: SubjectAccessReviewStatus{},
+		},
+		{
+			name:        "fail - check access error",
+			file:        "testdata/pod-get.json",
+			err:         errors.New("pdp unavailable"),
+			wantAction:  "Microsoft.Kubernetes/connectedClusters/pods/read",
+			wantScope:   cluster + "/namespaces/kittensandponies",
+			wantSubject: client.SubjectAttributes{ObjectId: "11111111-1111-1111-1111-111111111111", Groups: []string{"00000000-0000-0000-0000-0000000000a1"}},
+			wantStatus:  SubjectAccessReviewStatus{EvaluationError: "error while checking access, err: pdp unavailable"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pdp := &fakepdp.Client{Decisions: tt.decisions, Err: tt.err}
+			h, err := NewWebhook(Options{Client: pdp, ClusterResourceId: cluster})
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+
+			code, out := review(t, h, tt.file)
+			if code != http.StatusOK {
+				t.Fatalf("expected status to be '%d' but got '%d'", http.StatusOK, code)
+			}
+			if out.Kind != kindSubjectAccessReview || !strings.HasPrefix(out.APIVersion, "authorization.k8s.io/") {
+				t.Errorf("expected the review to be echoed but got '%s %s'", out.APIVersion, out.Kind)
+			}
+			got := out.Status
+			if got.Allowed != tt.wantStatus.Allowed || got.Denied != tt.wantStatus.Denied || got.EvaluationError != tt.wantStatus.EvaluationError {
+				t.Errorf("expected status to be '%+v' but got '%+v'", tt.wantStatus, got)
+			}
+			if got.Reason == "" && got.EvaluationError == "" {
+				t.Errorf("expected a reason")
+			}
+
+			if tt.wantAction == "" {
+				if pdp.Calls() != 0 {
+					t.Errorf("expected no CheckAccess but got '%d'", pdp.Calls())
+				}
+				return
+			}
+			if pdp.Calls() != 1 {
+				t.Fatalf("expected 1 CheckAccess but got '%d'", pdp.Calls())
+			}
+			req := pdp.Requests()[0]
+			if len(req.Actions) != 1 || req.Actions[0].Id != tt.wantAction || !req.Actions[0].IsDataAction {
+				t.Errorf("expected data action '%s' but got '%+v'", tt.wantAction, req.Actions)
+			}
+			if req.Resource.Id != tt.wantScope {
+				t.Errorf("expected scope '%s' but got '%s'", tt.wantScope, req.Resource.Id)
+			}
+			if req.Subject.Attributes.ObjectId != tt.wantSubject.ObjectId || strings.Join(req.Subject.Attributes.Groups, ",") != strings.Join(tt.wantSubject.Groups, ",") {
+				t.Errorf("expected subject '%+v' but got '%+v'", tt.wantSubject, req.Subject.Attributes)
+			}
+		})
+	}
+}
+
+func TestWebhookTemplates(t *testing.T) {
+	pdp := &fakepdp.Client{Decisions: map[string]client.AccessDecision{"Microsoft.ContainerService/managedClusters/deployments/delete": client.Allowed}}
+	h, err := NewWebhook(Options{
+		Client:                 pdp,
+		ClusterResourceId:      cluster + "/",
+		ActionTemplate:         "Microsoft.ContainerService/managedClusters/{resource}/{verb}",
+		NamespaceScopeTemplate: "{cluster}/providers/Microsoft.KubernetesConfiguration/namespaces/{namespace}",
+		Verbs:                  map[string]string{"delete": "delete"},
+		Subject: func(spec SubjectAccessReviewSpec) (client.SubjectAttributes, error) {
+			return client.SubjectAttributes{ObjectId: "custom", Groups: spec.Groups}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	_, out := review(t, h, "testdata/deployment-delete.json")
+	if !out.Status.Allowed {
+		t.Errorf("expected review to be allowed but got '%+v'", out.Status)
+	}
+	req := pdp.Requests()[0]
+	if want := cluster + "/providers/Microsoft.KubernetesConfiguration/namespaces/prod"; req.Resource.Id != want {
+		t.Errorf("expected scope '%s' but got '%s'", want, req.Resource.Id)
+	}
+	if req.Subject.Attributes.ObjectId != "custom" || len(req.Subject.Attributes.Groups) != 1 {
+		t.Errorf("expected the custom subject but got '%+v'", req.Subject.Attributes)
+	}
+
+	review(t, h, "testdata/nodes-list.json")
+	if pdp.Requests()[1].Actions[0].Id != "Microsoft.ContainerService/managedClusters/nodes/action" {
+		t.Errorf("expected the unmapped verb to become action but got '%s'", pdp.Requests()[1].Actions[0].Id)
+	}
+}
+
+func TestWebhookCache(t *testing.T) {
+	pdp := &fakepdp.Client{Decisions: map[string]client.AccessDecision{"Microsoft.Kubernetes/connectedClusters/pods/read": client.Allowed}}
+	h, err := NewWebhook(Options{Client: pdp, ClusterResourceId: cluster})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, out := review(t, h, "testdata/pod-get.json"); !out.Status.Allowed {
+			t.Fatalf("expected review to be allowed but got '%+v'", out.Status)
+		}
+	}
+	if pdp.Calls() != 1 {
+		t.Errorf("expected 1 CheckAccess but got '%d'", pdp.Calls())
+	}
+
+	pdp = &fakepdp.Client{Err: errors.New("pdp unavailable")}
+	h, err = NewWebhook(Options{Client: pdp, ClusterResourceId: cluster, AllowedTTL: -1})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	review(t, h, "testdata/pod-get.json")
+	if _, out := review(t, h, "testdata/pod-get.json"); out.Status.EvaluationError == "" {
+		t.Errorf("expected errors not to be cached but got '%+v'", out.Status)
+	}
+	if pdp.Calls() != 2 {
+		t.Errorf("expected 2 CheckAccess but got '%d'", pdp.Calls())
+	}
+
+	cache := &countingCache{DecisionCache: client.NewMemoryDecisionCache(10)}
+	h, err = NewWebhook(Options{Client: fakepdp.New(client.Allowed), ClusterResourceId: cluster, Cache: cache})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	review(t, h, "testdata/serviceaccount-get.json")
+	if cache.gets != 0 || cache.sets != 0 {
+		t.Errorf("expected system users not to be cached but got %d gets and %d sets", cache.gets, cache.sets)
+	}
+}
+
+// countingCache counts the calls to its DecisionCache
+type countingCache struct {
+	client.DecisionCache
+	gets, sets int
+}
+
+func (c *countingCache) Get(key string) (*client.AuthorizationDecisionResponse, bool) {
+	c.gets++
+	return c.DecisionCache.Get(key)
+}
+
+func (c *countingCache) Set(key string, res *client.AuthorizationDecisionResponse, ttl time.Duration) {
+	c.sets++
+	c.DecisionCache.Set(key, res, ttl)
+}
+
+func TestWebhookBadRequest(t *testing.T) {
+	h, err := NewWebhook(Options{Client: fakepdp.New(client.Allowed), ClusterResourceId: cluster})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	for _, tt := range []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "fail - method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "fail - body",
+			method:     http.MethodPost,
+			body:       "{",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "fail - kind",
+			method:     http.MethodPost,
+			body:       `{"apiVersion":"authorization.k8s.io/v1","kind":"SelfSubjectAccessReview"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, "/authorize", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status to be '%d' but got '%d'", tt.wantStatus, w.Code)
+			}
+		})
+	}
+
+	if _, err := NewWebhook(Options{ClusterResourceId: cluster}); err == nil {
+		t.Errorf("expected an error without client")
+	}
+	if _, err := NewWebhook(Options{Client: fakepdp.New(client.Allowed)}); err == nil {
+		t.Errorf("expected an error without cluster resource id")
+	}
+}
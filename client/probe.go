@@ -0,0 +1,142 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+// managementGroupPrefix is the prefix of the ARM IDs of management groups
+const managementGroupPrefix = "/providers/Microsoft.Management/managementGroups/"
+
+// ProbeOptions configures ProbeAccessScopes
+type ProbeOptions struct {
+	// ManagementGroups are the management groups above the subscription of
+	// the resource, from the closest to the root. Both names and ARM IDs are
+	// accepted. The hierarchy stops at the subscription when empty.
+	ManagementGroups []string
+
+	// FanOut configures the concurrency of the calls, one per scope
+	FanOut *FanOutOptions
+}
+
+// ScopeDecision is the outcome of the CheckAccess call at one scope of the
+// hierarchy. Exactly one of Response and Err is set.
+type ScopeDecision struct {
+	Scope    string
+	Response *AuthorizationDecisionResponse
+	Err      error
+}
+
+// ActionScopes tells where in the hierarchy an action is allowed
+type ActionScopes struct {
+	ActionId string
+	// AllowedAt are the probed scopes where the action is allowed, from the
+	// resource to the root
+	AllowedAt []string
+	// GrantedAt are the distinct scopes of the role assignments that allow
+	// the action, as returned in RoleAssignment.Scope
+	GrantedAt []string
+}
+
+// Allowed tells whether the action is allowed at any probed scope
+func (a ActionScopes) Allowed() bool {
+	return len(a.AllowedAt) > 0
+}
+
+// ScopeProbe is the result of ProbeAccessScopes
+type ScopeProbe struct {
+	ResourceId string
+	// Scopes are the decisions at each scope, from the resource to the root
+	Scopes []ScopeDecision
+	// Actions are in the order of the probed actions
+	Actions []ActionScopes
+}
+
+// ProbeAccessScopes checks actions for subject at every scope of the ARM
+// hierarchy of resourceId: the resource, its parent resources, its resource
+// group, its subscription and options.ManagementGroups. It reports, for each
+// action, the scopes where it is allowed and the scopes of the role
+// assignments granting it. The failure of the call at one scope is reported
+// in its ScopeDecision and does not fail the probe.
+func ProbeAccessScopes(ctx context.Context, client RemotePDPClient, subject SubjectInfo, actions []ActionInfo, resourceId string, options *ProbeOptions) (*ScopeProbe, error) {
+	if options == nil {
+		options = &ProbeOptions{}
+	}
+	scopes, err := scopeHierarchy(resourceId, options.ManagementGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := CheckAccessForResources(ctx, client, subject, actions, scopes, options.FanOut)
+	if err != nil {
+		return nil, err
+	}
+
+	probe := &ScopeProbe{ResourceId: resourceId}
+	for _, result := range results {
+		probe.Scopes = append(probe.Scopes, ScopeDecision{Scope: result.ResourceId, Response: result.Response, Err: result.Err})
+	}
+	// the decisions are looked up case-insensitively, as DecisionResult does
+	decisions := make([]*DecisionResult, len(results))
+	for i, result := range results {
+		decisions[i] = NewDecisionResult(actions, result.Response)
+	}
+	for _, action := range actions {
+		actionScopes := ActionScopes{ActionId: action.Id}
+		granted := map[string]bool{}
+		for i, result := range results {
+			if result.Err != nil {
+				continue
+			}
+			decision, ok := decisions[i].Decision(action.Id)
+			if !ok || decision.AccessDecision != Allowed {
+				continue
+			}
+			actionScopes.AllowedAt = append(actionScopes.AllowedAt, result.ResourceId)
+			if scope := decision.RoleAssignment.Scope; scope != "" && !granted[strings.ToLower(scope)] {
+				granted[strings.ToLower(scope)] = true
+				actionScopes.GrantedAt = append(actionScopes.GrantedAt, scope)
+			}
+		}
+		probe.Actions = append(probe.Actions, actionScopes)
+	}
+	return probe, nil
+}
+
+// ProbeAccessScopes is the package ProbeAccessScopes with r
+func (r *remotePDPClient) ProbeAccessScopes(ctx context.Context, subject SubjectInfo, actions []ActionInfo, resourceId string, options *ProbeOptions) (*ScopeProbe, error) {
+	return ProbeAccessScopes(ctx, r, subject, actions, resourceId, options)
+}
+
+// scopeHierarchy returns the scopes above resourceId, itself included, from
+// the lowest to the highest, followed by managementGroups
+func scopeHierarchy(resourceId string, managementGroups []string) ([]string, error) {
+	if resourceId == "" {
+		return nil, fmt.Errorf("need resource id in probing access scopes")
+	}
+	id, err := arm.ParseResourceID(resourceId)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing the resource id %s, err: %w", resourceId, err)
+	}
+
+	var scopes []string
+	for ; id != nil && id.ResourceType.String() != arm.TenantResourceType.String(); id = id.Parent {
+		scopes = append(scopes, id.String())
+	}
+	for _, mg := range managementGroups {
+		if mg == "" {
+			return nil, fmt.Errorf("need management group name in probing access scopes")
+		}
+		if !strings.HasPrefix(strings.ToLower(mg), strings.ToLower(managementGroupPrefix)) {
+			mg = managementGroupPrefix + mg
+		}
+		scopes = append(scopes, mg)
+	}
+	return scopes, nil
+}
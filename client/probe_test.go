@@ -0,0 +1,138 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+func TestScopeHierarchy(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		resourceId       string
+		managementGroups []string
+		want             []string
+		wantErr          string
+	}{
+		{
+			name:       "pass - child resource",
+			resourceId: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Sql/servers/s1/databases/db",
+			want: []string{
+				"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Sql/servers/s1/databases/db",
+				"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Sql/servers/s1",
+				"/subscriptions/sub/resourceGroups/rg",
+				"/subscriptions/sub",
+			},
+		},
+		{
+			name:             "pass - management groups by name and id",
+			resourceId:       "/subscriptions/sub",
+			managementGroups: []string{"mg", "/providers/Microsoft.Management/managementGroups/root"},
+			want: []string{
+				"/subscriptions/sub",
+				"/providers/Microsoft.Management/managementGroups/mg",
+				"/providers/Microsoft.Management/managementGroups/root",
+			},
+		},
+		{
+			name:    "fail - no resource id",
+			wantErr: "need resource id in probing access scopes",
+		},
+		{
+			name:       "fail - invalid resource id",
+			resourceId: "not-an-id",
+			wantErr:    "error while parsing the resource id not-an-id",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scopeHierarchy(tt.resourceId, tt.managementGroups)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error to start with '%s' but got '%v'", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("incorrect scopes: %v", diff)
+			}
+		})
+	}
+}
+
+func TestProbeAccessScopes(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	resourceId := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm"
+
+	// read is granted at the resource group, write at the management group,
+	// where the PDP answers it in other casing, delete nowhere and the
+	// resource call fails
+	transport := &fanOutTransport{respond: func(authzReq AuthorizationRequest) (int, string) {
+		scope := authzReq.Resource.Id
+		switch scope {
+		case resourceId:
+			return http.StatusForbidden, forbiddenBody
+		case "/subscriptions/sub/resourceGroups/rg", "/subscriptions/sub":
+			return http.StatusOK, `{"value":[
+				{"actionId":"read","accessDecision":"Allowed","roleAssignment":{"scope":"/subscriptions/sub/resourceGroups/rg"}},
+				{"actionId":"write","accessDecision":"NotAllowed"},
+				{"actionId":"delete","accessDecision":"NotAllowed"}]}`
+		default:
+			return http.StatusOK, fmt.Sprintf(`{"value":[
+				{"actionId":"read","accessDecision":"NotAllowed"},
+				{"actionId":"Write","accessDecision":"Allowed","roleAssignment":{"scope":%q}},
+				{"actionId":"delete","accessDecision":"NotAllowed"}]}`, scope)
+		}
+	}}
+	client, err := NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+
+	probe, err := ProbeAccessScopes(context.Background(), &countingClient{RemotePDPClient: client},
+		SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid"}},
+		[]ActionInfo{{Id: "read"}, {Id: "write"}, {Id: "delete"}},
+		resourceId,
+		&ProbeOptions{ManagementGroups: []string{"mg"}})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	if len(probe.Scopes) != 4 || probe.Scopes[0].Err == nil {
+		t.Errorf("expected 4 scopes with a failed resource scope but got %v", probe.Scopes)
+	}
+	want := []ActionScopes{
+		{
+			ActionId:  "read",
+			AllowedAt: []string{"/subscriptions/sub/resourceGroups/rg", "/subscriptions/sub"},
+			GrantedAt: []string{"/subscriptions/sub/resourceGroups/rg"},
+		},
+		{
+			ActionId:  "write",
+			AllowedAt: []string{"/providers/Microsoft.Management/managementGroups/mg"},
+			GrantedAt: []string{"/providers/Microsoft.Management/managementGroups/mg"},
+		},
+		{
+			ActionId: "delete",
+		},
+	}
+	if diff := cmp.Diff(want, probe.Actions); diff != "" {
+		t.Errorf("incorrect action scopes: %v", diff)
+	}
+	if probe.Actions[2].Allowed() {
+		t.Error("expected delete not to be allowed")
+	}
+}
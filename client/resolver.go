@@ -0,0 +1,259 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// ResourceTagAttributePrefix prefixes the name of a tag in the attribute
+// keys the ARM tags resolver returns, e.g. "Microsoft.Resources/tags:Project"
+const ResourceTagAttributePrefix = "Microsoft.Resources/tags:"
+
+// tagsAPIVersion is the api-version of the Microsoft.Resources/tags API
+const tagsAPIVersion = "2021-04-01"
+
+// ResourceAttributeResolver resolves the attributes of a resource, e.g. its
+// tags, that ABAC conditions are evaluated against. Implementations must be
+// safe for concurrent use.
+type ResourceAttributeResolver interface {
+	ResolveResourceAttributes(ctx context.Context, resourceId string) (Attributes, error)
+}
+
+// ResourceAttributeResolverFunc adapts a function to a ResourceAttributeResolver
+type ResourceAttributeResolverFunc func(ctx context.Context, resourceId string) (Attributes, error)
+
+func (f ResourceAttributeResolverFunc) ResolveResourceAttributes(ctx context.Context, resourceId string) (Attributes, error) {
+	return f(ctx, resourceId)
+}
+
+// ResourceAttributeOptions configures how the client resolves the attributes
+// of the resources of its requests
+type ResourceAttributeOptions struct {
+	// Resolver resolves the attributes. Nothing is resolved when nil.
+	Resolver ResourceAttributeResolver
+	// Timeout bounds the resolution of every request, unbounded when zero
+	Timeout time.Duration
+}
+
+// resolveResourceAttributes returns authzReq with the resolved attributes of
+// its resource. Attributes already in the request take precedence.
+func (r *remotePDPClient) resolveResourceAttributes(ctx context.Context, authzReq AuthorizationRequest) (AuthorizationRequest, error) {
+	if r.resolver == nil || r.resolver.Resolver == nil || authzReq.Resource.Id == "" {
+		return authzReq, nil
+	}
+	if r.resolver.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.resolver.Timeout)
+		defer cancel()
+	}
+
+	resolved, err := r.resolver.Resolver.ResolveResourceAttributes(ctx, authzReq.Resource.Id)
+	if err != nil {
+		return authzReq, fmt.Errorf("error while resolving the attributes of resource %s, err: %w", authzReq.Resource.Id, err)
+	}
+	if len(resolved) == 0 {
+		return authzReq, nil
+	}
+	attrs := Attributes{}
+	for k, v := range resolved {
+		attrs[k] = v
+	}
+	for k, v := range authzReq.Resource.Attributes {
+		attrs[k] = v
+	}
+	authzReq.Resource.Attributes = attrs
+	return authzReq, nil
+}
+
+// memoryResourceAttributes is an in-memory ResourceAttributeResolver
+type memoryResourceAttributes struct {
+	mu    sync.RWMutex
+	attrs map[string]Attributes
+}
+
+// NewMemoryResourceAttributeResolver returns a ResourceAttributeResolver
+// answering from attrs, keyed by case-insensitive resource IDs. Unknown
+// resources have no attributes.
+func NewMemoryResourceAttributeResolver(attrs map[string]Attributes) *memoryResourceAttributes {
+	m := &memoryResourceAttributes{attrs: map[string]Attributes{}}
+	for id, a := range attrs {
+		m.Set(id, a)
+	}
+	return m
+}
+
+// Set sets the attributes of resourceId
+func (m *memoryResourceAttributes) Set(resourceId string, attrs Attributes) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attrs[strings.ToLower(resourceId)] = attrs
+}
+
+func (m *memoryResourceAttributes) ResolveResourceAttributes(ctx context.Context, resourceId string) (Attributes, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.attrs[strings.ToLower(resourceId)], nil
+}
+
+// ChainResourceAttributeResolvers returns a ResourceAttributeResolver merging
+// the attributes of resolvers. The first resolver with a key wins, and the
+// first error fails the resolution.
+func ChainResourceAttributeResolvers(resolvers ...ResourceAttributeResolver) ResourceAttributeResolver {
+	return ResourceAttributeResolverFunc(func(ctx context.Context, resourceId string) (Attributes, error) {
+		attrs := Attributes{}
+		for _, resolver := range resolvers {
+			resolved, err := resolver.ResolveResourceAttributes(ctx, resourceId)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range resolved {
+				if _, ok := attrs[k]; !ok {
+					attrs[k] = v
+				}
+			}
+		}
+		return attrs, nil
+	})
+}
+
+// cachingResourceAttributes caches the attributes another resolver returns
+type cachingResourceAttributes struct {
+	inner      ResourceAttributeResolver
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]resolvedAttributes
+}
+
+type resolvedAttributes struct {
+	attrs     Attributes
+	expiresAt time.Time
+}
+
+// NewCachingResourceAttributeResolver returns a ResourceAttributeResolver
+// caching the attributes inner resolves during ttl, for up to maxEntries
+// resources. Errors are not cached.
+func NewCachingResourceAttributeResolver(inner ResourceAttributeResolver, ttl time.Duration, maxEntries int) *cachingResourceAttributes {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &cachingResourceAttributes{
+		inner:      inner,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[string]resolvedAttributes{},
+	}
+}
+
+func (c *cachingResourceAttributes) ResolveResourceAttributes(ctx context.Context, resourceId string) (Attributes, error) {
+	key := strings.ToLower(resourceId)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.attrs, nil
+	}
+
+	attrs, err := c.inner.ResolveResourceAttributes(ctx, resourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	for k := range c.entries {
+		if len(c.entries) < c.maxEntries {
+			break
+		}
+		delete(c.entries, k)
+	}
+	c.entries[key] = resolvedAttributes{attrs: attrs, expiresAt: now.Add(c.ttl)}
+	return attrs, nil
+}
+
+// armTagsResolver resolves the tags of resources with the ARM tags API
+type armTagsResolver struct {
+	endpoint string
+	pipeline runtime.Pipeline
+}
+
+var _ ResourceAttributeResolver = &armTagsResolver{}
+
+// NewARMTagsResolver returns a ResourceAttributeResolver reading the tags of
+// resources from ARM. A tag "Project" is returned with the key
+// "Microsoft.Resources/tags:Project". The endpoint and transport are the
+// ones of options.
+func NewARMTagsResolver(cred azcore.TokenCredential, options *arm.ClientOptions) (*armTagsResolver, error) {
+	if cred == nil {
+		return nil, fmt.Errorf("need TokenCredential in creating ARM tags resolver")
+	}
+	client, err := arm.NewClient(modulename, "v"+version, cred, options)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating the ARM client, err: %w", err)
+	}
+	return &armTagsResolver{endpoint: client.Endpoint(), pipeline: client.Pipeline()}, nil
+}
+
+// tagsResource is the response of the ARM tags API
+type tagsResource struct {
+	Properties struct {
+		Tags map[string]string `json:"tags"`
+	} `json:"properties"`
+}
+
+func (a *armTagsResolver) ResolveResourceAttributes(ctx context.Context, resourceId string) (Attributes, error) {
+	url := runtime.JoinPaths(a.endpoint, resourceId, "providers/Microsoft.Resources/tags/default")
+	req, err := runtime.NewRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating the tags request, err: %w", err)
+	}
+	query := req.Raw().URL.Query()
+	query.Set("api-version", tagsAPIVersion)
+	req.Raw().URL.RawQuery = query.Encode()
+	req.Raw().Header.Set("Accept", "application/json")
+
+	res, err := a.pipeline.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting the tags, err: %w", err)
+	}
+	if !runtime.HasStatusCode(res, http.StatusOK) {
+		return nil, runtime.NewResponseError(res)
+	}
+	payload, err := runtime.Payload(res)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading the tags, err: %w", err)
+	}
+	var tags tagsResource
+	if err := json.Unmarshal(payload, &tags); err != nil {
+		return nil, fmt.Errorf("error while parsing the tags, err: %w", err)
+	}
+
+	attrs := Attributes{}
+	for name, value := range tags.Properties.Tags {
+		attrs[ResourceTagAttributePrefix+name] = value
+	}
+	return attrs, nil
+}
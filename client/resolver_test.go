@@ -0,0 +1,192 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+// transportFunc adapts a function to a policy.Transporter
+type transportFunc func(req *http.Request) (*http.Response, error)
+
+func (f transportFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCheckAccessResolvesResourceAttributes(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	resourceId := "/subscriptions/sub/resourceGroups/rg"
+
+	for _, tt := range []struct {
+		name      string
+		resolver  ResourceAttributeResolver
+		timeout   time.Duration
+		attrs     Attributes
+		wantAttrs map[string]interface{}
+		wantErr   string
+	}{
+		{
+			name: "pass - resolved attributes are merged, request attributes win",
+			resolver: ChainResourceAttributeResolvers(
+				NewMemoryResourceAttributeResolver(map[string]Attributes{strings.ToUpper(resourceId): {"Microsoft.Resources/tags:Project": "alpha", "owner": "memory"}}),
+				ResourceAttributeResolverFunc(func(ctx context.Context, resourceId string) (Attributes, error) {
+					return Attributes{"Microsoft.Resources/tags:Project": "beta", "cost": "chained"}, nil
+				}),
+			),
+			attrs:     Attributes{"owner": "request"},
+			wantAttrs: map[string]interface{}{"Microsoft.Resources/tags:Project": "alpha", "owner": "request", "cost": "chained"},
+		},
+		{
+			name: "fail - resolver error",
+			resolver: ResourceAttributeResolverFunc(func(ctx context.Context, resourceId string) (Attributes, error) {
+				return nil, errors.New("unavailable")
+			}),
+			wantErr: "error while resolving the attributes of resource /subscriptions/sub/resourceGroups/rg, err: unavailable",
+		},
+		{
+			name: "fail - resolver timeout",
+			resolver: ResourceAttributeResolverFunc(func(ctx context.Context, resourceId string) (Attributes, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}),
+			timeout: time.Millisecond,
+			wantErr: "error while resolving the attributes of resource /subscriptions/sub/resourceGroups/rg, err: context deadline exceeded",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]interface{}
+			transport := transportFunc(func(req *http.Request) (*http.Response, error) {
+				var authzReq struct {
+					Resource struct{ Attributes map[string]interface{} }
+				}
+				if err := json.NewDecoder(req.Body).Decode(&authzReq); err != nil {
+					return nil, err
+				}
+				sent = authzReq.Resource.Attributes
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{"value":[]}`)), Request: req}, nil
+			})
+			client, err := NewRemotePDPClientWithOptions(endpoint, "scope", test.FakeCredential{}, &ClientOptions{
+				ClientOptions:      azcore.ClientOptions{Transport: transport},
+				ResourceAttributes: &ResourceAttributeOptions{Resolver: tt.resolver, Timeout: tt.timeout},
+			})
+			if err != nil {
+				t.Fatalf("Unable to create a new PDP client: %v", err)
+			}
+
+			_, err = client.CheckAccess(context.Background(), AuthorizationRequest{Resource: ResourceInfo{Id: resourceId, Attributes: tt.attrs}})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error to be '%s' but got '%v'", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(tt.wantAttrs, sent); diff != "" {
+				t.Errorf("incorrect resource attributes: %v", diff)
+			}
+			if len(tt.attrs) != 1 {
+				t.Errorf("expected the attributes of the request not to be modified but got %v", tt.attrs)
+			}
+		})
+	}
+}
+
+func TestCachingResourceAttributeResolver(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	cache := NewCachingResourceAttributeResolver(ResourceAttributeResolverFunc(func(ctx context.Context, resourceId string) (Attributes, error) {
+		calls++
+		return Attributes{"id": resourceId}, nil
+	}), time.Minute, 2)
+	cache.now = func() time.Time { return now }
+
+	for _, id := range []string{"a", "A", "b", "a"} {
+		if _, err := cache.ResolveResourceAttributes(context.Background(), id); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls but got %d", calls)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.ResolveResourceAttributes(context.Background(), "a"); err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls after expiry but got %d", calls)
+	}
+}
+
+func TestARMTagsResolver(t *testing.T) {
+	resourceId := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/sa"
+	for _, tt := range []struct {
+		name    string
+		status  int
+		body    string
+		want    Attributes
+		wantErr bool
+	}{
+		{
+			name:   "pass - tags are prefixed",
+			status: http.StatusOK,
+			body:   `{"id":"x","properties":{"tags":{"Project":"alpha","Env":"prod"}}}`,
+			want:   Attributes{"Microsoft.Resources/tags:Project": "alpha", "Microsoft.Resources/tags:Env": "prod"},
+		},
+		{
+			name:    "fail - not found",
+			status:  http.StatusNotFound,
+			body:    `{"error":{"code":"ResourceNotFound","message":"not found"}}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotURL string
+			resolver, err := NewARMTagsResolver(test.FakeCredential{}, &arm.ClientOptions{ClientOptions: policy.ClientOptions{
+				Transport: transportFunc(func(req *http.Request) (*http.Response, error) {
+					gotURL = req.URL.String()
+					return &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body)), Request: req}, nil
+				}),
+				Retry: policy.RetryOptions{MaxRetries: -1},
+			}})
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+
+			got, err := resolver.ResolveResourceAttributes(context.Background(), resourceId)
+			if wantURL := "https://management.azure.com" + resourceId + "/providers/Microsoft.Resources/tags/default?api-version=2021-04-01"; gotURL != wantURL {
+				t.Errorf("expected request to %s but got %s", wantURL, gotURL)
+			}
+			if tt.wantErr {
+				var responseErr *azcore.ResponseError
+				if !errors.As(err, &responseErr) || responseErr.StatusCode != tt.status {
+					t.Errorf("expected a ResponseError with status %d but got '%v'", tt.status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("incorrect tags: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"strings"
+)
+
+// DecisionResult gives per action access to the decisions of a CheckAccess
+// call. Action IDs are compared case-insensitively, as ARM does.
+type DecisionResult struct {
+	// Actions are the requested actions
+	Actions []ActionInfo
+	// Response is the raw response of the PDP
+	Response *AuthorizationDecisionResponse
+
+	decisions map[string]*AuthorizationDecision
+}
+
+// NewDecisionResult returns the DecisionResult of res, the response to a
+// request for actions
+func NewDecisionResult(actions []ActionInfo, res *AuthorizationDecisionResponse) *DecisionResult {
+	result := &DecisionResult{
+		Actions:   actions,
+		Response:  res,
+		decisions: map[string]*AuthorizationDecision{},
+	}
+	if res != nil {
+		for i := range res.Value {
+			key := strings.ToLower(res.Value[i].ActionId)
+			if _, ok := result.decisions[key]; !ok {
+				result.decisions[key] = &res.Value[i]
+			}
+		}
+	}
+	return result
+}
+
+// Decision returns the decision of action, if the response has one
+func (d *DecisionResult) Decision(action string) (*AuthorizationDecision, bool) {
+	decision, ok := d.decisions[strings.ToLower(action)]
+	return decision, ok
+}
+
+// AllAllowed tells whether every requested action is allowed. An action
+// missing from the response is not allowed.
+func (d *DecisionResult) AllAllowed() bool {
+	if len(d.Actions) == 0 {
+		return false
+	}
+	for _, action := range d.Actions {
+		if decision, ok := d.Decision(action.Id); !ok || decision.AccessDecision != Allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// AnyDenied tells whether a deny assignment blocks any requested action
+func (d *DecisionResult) AnyDenied() bool {
+	return len(d.DeniedActions()) > 0
+}
+
+// DeniedActions returns the requested actions a deny assignment blocks
+func (d *DecisionResult) DeniedActions() []string {
+	return d.actionsWith(func(decision *AuthorizationDecision, ok bool) bool {
+		return ok && decision.AccessDecision == Denied
+	})
+}
+
+// NotAllowedActions returns the requested actions no role assignment grants.
+// Explicitly denied and missing actions are not included.
+func (d *DecisionResult) NotAllowedActions() []string {
+	return d.actionsWith(func(decision *AuthorizationDecision, ok bool) bool {
+		return ok && decision.AccessDecision == NotAllowed
+	})
+}
+
+// MissingActions returns the requested actions the response has no decision for
+func (d *DecisionResult) MissingActions() []string {
+	return d.actionsWith(func(_ *AuthorizationDecision, ok bool) bool {
+		return !ok
+	})
+}
+
+// actionsWith returns the IDs of the requested actions matching keep, in
+// the order they were requested
+func (d *DecisionResult) actionsWith(keep func(decision *AuthorizationDecision, ok bool) bool) []string {
+	var ids []string
+	for _, action := range d.Actions {
+		if keep(d.Decision(action.Id)) {
+			ids = append(ids, action.Id)
+		}
+	}
+	return ids
+}
+
+// CheckAccessWithResult is CheckAccess returning a DecisionResult
+func (r *remotePDPClient) CheckAccessWithResult(ctx context.Context, authzReq AuthorizationRequest) (*DecisionResult, error) {
+	res, err := r.CheckAccess(ctx, authzReq)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecisionResult(authzReq.Actions, res), nil
+}
@@ -0,0 +1,117 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+func TestDecisionResult(t *testing.T) {
+	actions := []ActionInfo{{Id: "Microsoft.Compute/virtualMachines/read"}, {Id: "write"}, {Id: "delete"}, {Id: "action"}}
+
+	for _, tt := range []struct {
+		name               string
+		actions            []ActionInfo
+		res                *AuthorizationDecisionResponse
+		wantAllAllowed     bool
+		wantAnyDenied      bool
+		wantDenied         []string
+		wantNotAllowed     []string
+		wantMissing        []string
+		wantReadDecision   AccessDecision
+		wantReadDecisionOk bool
+	}{
+		{
+			name:    "pass - every action allowed, ignoring case",
+			actions: actions[:2],
+			res: &AuthorizationDecisionResponse{Value: []AuthorizationDecision{
+				{ActionId: "microsoft.compute/virtualmachines/read", AccessDecision: Allowed},
+				{ActionId: "write", AccessDecision: Allowed},
+			}},
+			wantAllAllowed:     true,
+			wantReadDecision:   Allowed,
+			wantReadDecisionOk: true,
+		},
+		{
+			name:    "pass - denied, not allowed and missing actions",
+			actions: actions,
+			res: &AuthorizationDecisionResponse{Value: []AuthorizationDecision{
+				{ActionId: "Microsoft.Compute/virtualMachines/read", AccessDecision: Allowed},
+				{ActionId: "write", AccessDecision: Denied},
+				{ActionId: "delete", AccessDecision: NotAllowed},
+			}},
+			wantAnyDenied:      true,
+			wantDenied:         []string{"write"},
+			wantNotAllowed:     []string{"delete"},
+			wantMissing:        []string{"action"},
+			wantReadDecision:   Allowed,
+			wantReadDecisionOk: true,
+		},
+		{
+			name:        "pass - nil response",
+			actions:     actions[:1],
+			wantMissing: []string{"Microsoft.Compute/virtualMachines/read"},
+		},
+		{
+			name: "pass - no requested action is not all allowed",
+			res:  &AuthorizationDecisionResponse{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewDecisionResult(tt.actions, tt.res)
+			if got := result.AllAllowed(); got != tt.wantAllAllowed {
+				t.Errorf("expected AllAllowed to be %v but got %v", tt.wantAllAllowed, got)
+			}
+			if got := result.AnyDenied(); got != tt.wantAnyDenied {
+				t.Errorf("expected AnyDenied to be %v but got %v", tt.wantAnyDenied, got)
+			}
+			if diff := cmp.Diff(tt.wantDenied, result.DeniedActions()); diff != "" {
+				t.Errorf("incorrect denied actions: %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantNotAllowed, result.NotAllowedActions()); diff != "" {
+				t.Errorf("incorrect not allowed actions: %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantMissing, result.MissingActions()); diff != "" {
+				t.Errorf("incorrect missing actions: %v", diff)
+			}
+			decision, ok := result.Decision("MICROSOFT.COMPUTE/VIRTUALMACHINES/READ")
+			if ok != tt.wantReadDecisionOk || (ok && decision.AccessDecision != tt.wantReadDecision) {
+				t.Errorf("expected read decision %v %v but got %v %v", tt.wantReadDecision, tt.wantReadDecisionOk, decision, ok)
+			}
+		})
+	}
+}
+
+func TestCheckAccessWithResult(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	body := `{"value":[{"actionId":"read","accessDecision":"Allowed"},{"actionId":"write","accessDecision":"Denied"}]}`
+	client, err := NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{Transport: test.CreateTransport(http.StatusOK, body)})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+
+	result, err := client.CheckAccessWithResult(context.Background(), AuthorizationRequest{Actions: []ActionInfo{{Id: "read"}, {Id: "write"}}})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if result.AllAllowed() || !result.AnyDenied() {
+		t.Errorf("expected write to be denied but got %v", result.Response)
+	}
+
+	client, err = NewRemotePDPClient(endpoint, "scope", test.FakeCredential{}, &azcore.ClientOptions{Transport: test.CreateTransport(http.StatusForbidden, forbiddenBody)})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+	if result, err := client.CheckAccessWithResult(context.Background(), AuthorizationRequest{}); err == nil || result != nil {
+		t.Errorf("expected only an error but got %v and '%v'", result, err)
+	}
+}
@@ -0,0 +1,143 @@
+package routepolicy
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// LintIssueKind identifies an issue found by Lint
+type LintIssueKind string
+
+// LintIssueKind possible values
+const (
+	// IssueUnmappedRoute is a route of the service no policy route maps
+	IssueUnmappedRoute LintIssueKind = "UnmappedRoute"
+	// IssueUnknownAction is an action of the policy the provider does not define
+	IssueUnknownAction LintIssueKind = "UnknownAction"
+)
+
+// LintIssue is an issue found by Lint
+type LintIssue struct {
+	Kind    LintIssueKind `json:"kind"`
+	Route   string        `json:"route"`
+	Action  string        `json:"action,omitempty"`
+	Message string        `json:"message"`
+}
+
+// RouteSpec is a route the service serves
+type RouteSpec struct {
+	Method string
+	Path   string
+}
+
+func (r RouteSpec) String() string {
+	return r.Method + " " + r.Path
+}
+
+// LintOptions is what Lint checks the policy against
+type LintOptions struct {
+	// Routes are the routes the service serves. Unmapped routes are not
+	// reported when empty.
+	Routes []RouteSpec
+	// KnownActions are the actions the provider defines. Unknown actions
+	// are not reported when empty.
+	KnownActions []string
+}
+
+// Lint reports the routes of options.Routes no policy route maps, and the
+// actions of the policy missing from options.KnownActions
+func Lint(policy *Policy, options LintOptions) []LintIssue {
+	var issues []LintIssue
+
+	for _, spec := range options.Routes {
+		method := strings.ToUpper(spec.Method)
+		if _, ok := policy.Match(method, spec.Path); !ok {
+			issues = append(issues, LintIssue{
+				Kind:    IssueUnmappedRoute,
+				Route:   method + " " + spec.Path,
+				Message: fmt.Sprintf("no policy route maps %s %s", method, spec.Path),
+			})
+		}
+	}
+
+	if len(options.KnownActions) > 0 {
+		known := map[string]bool{}
+		for _, action := range options.KnownActions {
+			known[strings.ToLower(action)] = true
+		}
+		for _, route := range policy.Routes {
+			for _, action := range route.Actions {
+				if !known[strings.ToLower(action.Id)] {
+					issues = append(issues, LintIssue{
+						Kind:    IssueUnknownAction,
+						Route:   route.Method + " " + route.Path,
+						Action:  action.Id,
+						Message: fmt.Sprintf("action %s is not an operation of the provider", action.Id),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Kind < issues[j].Kind
+	})
+	return issues
+}
+
+// ParseRouteSpecs reads one "METHOD /path" route per line. Blank lines and
+// lines starting with # are skipped.
+func ParseRouteSpecs(r io.Reader) ([]RouteSpec, error) {
+	var specs []RouteSpec
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return nil, fmt.Errorf("line %d: %s is not valid, need METHOD /path", n, line)
+		}
+		specs = append(specs, RouteSpec{Method: strings.ToUpper(fields[0]), Path: fields[1]})
+	}
+	return specs, scanner.Err()
+}
+
+// operationList is the response of the operations API of a provider
+type operationList struct {
+	Value []struct {
+		Name string `json:"name"`
+	} `json:"value"`
+}
+
+// ParseOperations reads the actions of a provider, either from the JSON
+// response of its operations API or from one action per line
+func ParseOperations(data []byte) ([]string, error) {
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var operations operationList
+		if err := json.Unmarshal(data, &operations); err != nil {
+			return nil, fmt.Errorf("error while parsing the operations, err: %w", err)
+		}
+		var actions []string
+		for _, operation := range operations.Value {
+			actions = append(actions, operation.Name)
+		}
+		return actions, nil
+	}
+
+	var actions []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			actions = append(actions, line)
+		}
+	}
+	return actions, nil
+}
@@ -0,0 +1,65 @@
+package routepolicy
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	p, err := Load("testdata/policy.yaml")
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	routes, err := ParseRouteSpecs(strings.NewReader(`
+# widgets
+GET /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Contoso/widgets/{widgetName}
+put /subscriptions/{sub}/resourceGroups/{rg}/providers/Microsoft.Contoso/widgets/{name}
+DELETE /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Contoso/widgets/{widgetName}
+`))
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	actions, err := ParseOperations([]byte(`{"value":[{"name":"Microsoft.Contoso/widgets/read"},{"name":"microsoft.contoso/widgets/write"}]}`))
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	got := Lint(p, LintOptions{Routes: routes, KnownActions: actions})
+	want := []LintIssue{
+		{
+			Kind:    IssueUnknownAction,
+			Route:   "GET /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Contoso/widgets/{widgetName}/blobs/{blobName}",
+			Action:  "Microsoft.Contoso/widgets/blobs/read",
+			Message: "action Microsoft.Contoso/widgets/blobs/read is not an operation of the provider",
+		},
+		{
+			Kind:    IssueUnmappedRoute,
+			Route:   "DELETE /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Contoso/widgets/{widgetName}",
+			Message: "no policy route maps DELETE /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Contoso/widgets/{widgetName}",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("incorrect issues: %v", diff)
+	}
+}
+
+func TestParseRouteSpecsInvalid(t *testing.T) {
+	if _, err := ParseRouteSpecs(strings.NewReader("GET\n")); err == nil || err.Error() != "line 1: GET is not valid, need METHOD /path" {
+		t.Errorf("expected error to be 'line 1: GET is not valid, need METHOD /path' but got '%v'", err)
+	}
+}
+
+func TestParseOperationsLines(t *testing.T) {
+	got, err := ParseOperations([]byte("# actions\nMicrosoft.Contoso/widgets/read\n\nMicrosoft.Contoso/widgets/write\n"))
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if diff := cmp.Diff([]string{"Microsoft.Contoso/widgets/read", "Microsoft.Contoso/widgets/write"}, got); diff != "" {
+		t.Errorf("incorrect actions: %v", diff)
+	}
+}
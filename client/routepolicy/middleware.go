@@ -0,0 +1,112 @@
+package routepolicy
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// MiddlewareOptions configures the enforcement middleware
+type MiddlewareOptions struct {
+	// Client decides the requests
+	Client client.RemotePDPClient
+	// Subject returns the subject of a request, the caller forwarded by ARM
+	// (client.ARMRequestSubject) when nil
+	Subject func(r *http.Request) client.SubjectSource
+	// AllowUnmapped lets the requests matching no route through. They are
+	// rejected with a 403 otherwise.
+	AllowUnmapped bool
+	// OnDenied writes the response of a request whose actions are not all
+	// allowed, an ARM AuthorizationFailed error when nil
+	OnDenied func(w http.ResponseWriter, r *http.Request, authzReq client.AuthorizationRequest, res *client.AuthorizationDecisionResponse)
+	// OnUnauthenticated writes the response of a request whose subject
+	// could not be derived, an ARM AuthenticationFailed error with a 401
+	// when nil
+	OnUnauthenticated func(w http.ResponseWriter, r *http.Request, err error)
+	// OnError writes the response of a request that could not be decided,
+	// e.g. because the PDP failed, a 500 when nil
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Middleware returns a middleware enforcing the policy: the actions of the
+// route a request matches must all be allowed for the request to reach the
+// next handler, which finds the decision in client.AuthorizerFromContext.
+func Middleware(policy *Policy, options MiddlewareOptions) (func(http.Handler) http.Handler, error) {
+	if policy == nil {
+		return nil, fmt.Errorf("need policy in creating middleware")
+	}
+	if options.Client == nil {
+		return nil, fmt.Errorf("need client in creating middleware")
+	}
+	if options.Subject == nil {
+		options.Subject = func(r *http.Request) client.SubjectSource {
+			return client.ARMRequestSubject(r.Header)
+		}
+	}
+	if options.OnDenied == nil {
+		options.OnDenied = func(w http.ResponseWriter, r *http.Request, authzReq client.AuthorizationRequest, res *client.AuthorizationDecisionResponse) {
+			armErr := client.NewAuthorizationFailedError(authzReq, res, nil)
+			if armErr == nil {
+				armErr = &client.ARMErrorResponse{Error: client.ErrorDetail{Code: client.ARMCodeAuthorizationFailed, Message: "The request is not authorized."}}
+			}
+			_ = armErr.Write(w)
+		}
+	}
+	if options.OnUnauthenticated == nil {
+		options.OnUnauthenticated = func(w http.ResponseWriter, r *http.Request, err error) {
+			_ = (&client.ARMErrorResponse{Error: client.ErrorDetail{
+				Code:    client.ARMCodeAuthenticationFailed,
+				Message: "The caller of the request could not be authenticated.",
+			}}).WriteStatus(w, http.StatusUnauthorized)
+		}
+	}
+	if options.OnError == nil {
+		options.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "the request could not be authorized", http.StatusInternalServerError)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			match, ok := policy.Match(r.Method, r.URL.Path)
+			if !ok {
+				if options.AllowUnmapped {
+					next.ServeHTTP(w, r)
+					return
+				}
+				_ = (&client.ARMErrorResponse{Error: client.ErrorDetail{
+					Code:    client.ARMCodeAuthorizationFailed,
+					Message: fmt.Sprintf("No authorization policy maps %s %s.", r.Method, r.URL.Path),
+				}}).Write(w)
+				return
+			}
+
+			subject, err := options.Subject(r).Subject(r.Context())
+			if err != nil {
+				options.OnUnauthenticated(w, r, err)
+				return
+			}
+			authzReq := match.AuthorizationRequest(r, subject)
+
+			authorizer, err := client.NewAuthorizer(options.Client, authzReq.Subject, authzReq.Resource, authzReq.Environment)
+			if err != nil {
+				options.OnError(w, r, err)
+				return
+			}
+			result, err := authorizer.Check(r.Context(), authzReq.Actions...)
+			if err != nil {
+				options.OnError(w, r, err)
+				return
+			}
+			if !result.AllAllowed() {
+				options.OnDenied(w, r, authzReq, result.Response)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(client.WithAuthorizer(r.Context(), authorizer)))
+		})
+	}, nil
+}
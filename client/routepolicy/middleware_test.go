@@ -0,0 +1,121 @@
+package routepolicy
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/fakepdp"
+)
+
+func TestMiddleware(t *testing.T) {
+	p, err := Load("testdata/policy.yaml")
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	for _, tt := range []struct {
+		name          string
+		method        string
+		path          string
+		noObjectId    bool
+		allowUnmapped bool
+		err           error
+		wantStatus    int
+		wantCode      string
+	}{
+		{
+			name:       "pass - allowed",
+			method:     http.MethodGet,
+			path:       widget,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "fail - not allowed",
+			method:     http.MethodPut,
+			path:       widget,
+			wantStatus: http.StatusForbidden,
+			wantCode:   client.ARMCodeAuthorizationFailed,
+		},
+		{
+			name:       "fail - unmapped route",
+			method:     http.MethodDelete,
+			path:       widget,
+			wantStatus: http.StatusForbidden,
+			wantCode:   client.ARMCodeAuthorizationFailed,
+		},
+		{
+			name:          "pass - unmapped route allowed",
+			method:        http.MethodDelete,
+			path:          widget,
+			allowUnmapped: true,
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:       "fail - no subject",
+			method:     http.MethodGet,
+			path:       widget,
+			noObjectId: true,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   client.ARMCodeAuthenticationFailed,
+		},
+		{
+			name:       "fail - PDP error",
+			method:     http.MethodGet,
+			path:       widget,
+			err:        errors.New("unavailable"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pdp := &fakepdp.Client{Decisions: map[string]client.AccessDecision{"Microsoft.Contoso/widgets/read": client.Allowed}, Err: tt.err}
+			middleware, err := Middleware(p, MiddlewareOptions{Client: pdp, AllowUnmapped: tt.allowUnmapped})
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if a, ok := client.AuthorizerFromContext(r.Context()); ok {
+					a.MarkPerformed(client.ActionInfo{Id: "Microsoft.Contoso/widgets/read"})
+					if !a.Report().Complete() {
+						t.Error("expected the performed action to be authorized")
+					}
+				} else if !tt.allowUnmapped {
+					t.Error("expected an authorizer in the context")
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if !tt.noObjectId {
+				r.Header.Set(client.HeaderClientObjectId, "oid")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d but got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantCode != "" {
+				var armErr client.ARMErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &armErr); err != nil || armErr.Error.Code != tt.wantCode {
+					t.Errorf("expected code %s but got %s '%v'", tt.wantCode, w.Body.String(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareInvalid(t *testing.T) {
+	if _, err := Middleware(nil, MiddlewareOptions{Client: fakepdp.New(client.Allowed)}); err == nil || err.Error() != "need policy in creating middleware" {
+		t.Errorf("expected error to be 'need policy in creating middleware' but got '%v'", err)
+	}
+	if _, err := Middleware(&Policy{}, MiddlewareOptions{}); err == nil || err.Error() != "need client in creating middleware" {
+		t.Errorf("expected error to be 'need client in creating middleware' but got '%v'", err)
+	}
+}
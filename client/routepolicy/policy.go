@@ -0,0 +1,362 @@
+package routepolicy
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+// Package routepolicy maps the HTTP routes of a resource provider to the
+// actions they require, from a YAML or JSON policy file, and enforces them
+// with a RemotePDPClient.
+//
+// A policy file looks like:
+//
+//	routes:
+//	- method: PUT
+//	  path: /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Contoso/widgets/{widgetName}
+//	  resource: /subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Contoso/widgets/{widgetName}
+//	  actions:
+//	  - id: Microsoft.Contoso/widgets/write
+//	  attributes:
+//	  - name: Microsoft.Contoso/widgets:sku
+//	    from: query
+//	    key: sku
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// Sources of the attributes extracted from requests
+const (
+	SourcePath   = "path"
+	SourceQuery  = "query"
+	SourceHeader = "header"
+)
+
+// Targets of the attributes extracted from requests
+const (
+	TargetResource    = "resource"
+	TargetEnvironment = "environment"
+)
+
+// parameter matches the parameters of path and resource templates
+var parameter = regexp.MustCompile(`^\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// Policy maps routes to the actions they require
+type Policy struct {
+	Routes []Route `yaml:"routes" json:"routes"`
+}
+
+// Route is the authorization mapping of an HTTP route
+type Route struct {
+	// Name optionally identifies the route, so a proxy knowing the route of
+	// a request can select it with MatchNamed
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Method is the HTTP method of the route, "*" for any
+	Method string `yaml:"method" json:"method"`
+	// Path is the path template of the route, with {name} parameters
+	Path string `yaml:"path" json:"path"`
+	// Resource is the template of the resource ID, with the parameters of Path
+	Resource string `yaml:"resource" json:"resource"`
+	// Actions are the actions the route requires
+	Actions []Action `yaml:"actions" json:"actions"`
+	// Attributes are extracted from the request into the AuthorizationRequest
+	Attributes []AttributeRule `yaml:"attributes,omitempty" json:"attributes,omitempty"`
+
+	segments []string
+}
+
+// Action is an action a route requires
+type Action struct {
+	Id           string `yaml:"id" json:"id"`
+	IsDataAction bool   `yaml:"isDataAction,omitempty" json:"isDataAction,omitempty"`
+}
+
+// AttributeRule extracts an attribute from a request
+type AttributeRule struct {
+	// Name is the attribute key in the AuthorizationRequest
+	Name string `yaml:"name" json:"name"`
+	// From is where the value is read: path, query or header
+	From string `yaml:"from" json:"from"`
+	// Key is the path parameter, query parameter or header to read
+	Key string `yaml:"key" json:"key"`
+	// Target is where the attribute goes: resource, the default, or environment
+	Target string `yaml:"target,omitempty" json:"target,omitempty"`
+}
+
+// Load reads and validates the policy file at path
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading the policy file, err: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates a YAML or JSON policy
+func Parse(data []byte) (*Policy, error) {
+	p := &Policy{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(p); err != nil {
+		return nil, fmt.Errorf("error while parsing the policy, err: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate checks every route of the policy and prepares them for matching.
+// Routes are matched in order, so a route whose requests all match an
+// earlier route, e.g. one of any method or with a parameter in place of a
+// literal, is rejected as shadowed.
+func (p *Policy) Validate() error {
+	seen := map[string]int{}
+	names := map[string]int{}
+	for i := range p.Routes {
+		route := &p.Routes[i]
+		if err := route.validate(); err != nil {
+			return fmt.Errorf("route %d (%s %s): %w", i, route.Method, route.Path, err)
+		}
+		key := route.Method + " " + normalizeTemplate(route.Path)
+		if j, ok := seen[key]; ok {
+			return fmt.Errorf("route %d (%s %s): duplicates route %d", i, route.Method, route.Path, j)
+		}
+		seen[key] = i
+		for j := range p.Routes[:i] {
+			if p.Routes[j].shadows(route) {
+				return fmt.Errorf("route %d (%s %s): is shadowed by route %d (%s %s)", i, route.Method, route.Path, j, p.Routes[j].Method, p.Routes[j].Path)
+			}
+		}
+		if route.Name != "" {
+			if j, ok := names[route.Name]; ok {
+				return fmt.Errorf("route %d (%s %s): name %s duplicates route %d", i, route.Method, route.Path, route.Name, j)
+			}
+			names[route.Name] = i
+		}
+	}
+	return nil
+}
+
+func (r *Route) validate() error {
+	r.Method = strings.ToUpper(strings.TrimSpace(r.Method))
+	switch r.Method {
+	case "*", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return fmt.Errorf("method: %s is not valid", r.Method)
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("path: %s is not valid, need an absolute path", r.Path)
+	}
+
+	r.segments = splitPath(r.Path)
+	params := map[string]bool{}
+	for _, segment := range r.segments {
+		if name, ok := parameterName(segment); ok {
+			if params[name] {
+				return fmt.Errorf("path: parameter %s is repeated", name)
+			}
+			params[name] = true
+		} else if strings.ContainsAny(segment, "{}") {
+			return fmt.Errorf("path: segment %s is not valid, need a literal or a {parameter}", segment)
+		}
+	}
+
+	if !strings.HasPrefix(r.Resource, "/") {
+		return fmt.Errorf("resource: %s is not valid, need a resource ID template", r.Resource)
+	}
+	for _, segment := range splitPath(r.Resource) {
+		if name, ok := parameterName(segment); ok && !params[name] {
+			return fmt.Errorf("resource: parameter %s is not a parameter of the path", name)
+		}
+	}
+
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("need actions")
+	}
+	for _, action := range r.Actions {
+		if strings.TrimSpace(action.Id) == "" {
+			return fmt.Errorf("need action id")
+		}
+	}
+
+	for _, attr := range r.Attributes {
+		if attr.Name == "" || attr.Key == "" {
+			return fmt.Errorf("attribute: need name and key")
+		}
+		switch attr.From {
+		case SourcePath:
+			if !params[attr.Key] {
+				return fmt.Errorf("attribute %s: %s is not a parameter of the path", attr.Name, attr.Key)
+			}
+		case SourceQuery, SourceHeader:
+		default:
+			return fmt.Errorf("attribute %s: from: %s is not valid, need one of path, query, header", attr.Name, attr.From)
+		}
+		switch attr.Target {
+		case "", TargetResource, TargetEnvironment:
+		default:
+			return fmt.Errorf("attribute %s: target: %s is not valid, need one of resource, environment", attr.Name, attr.Target)
+		}
+	}
+	return nil
+}
+
+// Match is a route matching a request
+type Match struct {
+	Route *Route
+	// Params are the values of the path parameters
+	Params map[string]string
+}
+
+// Match returns the first route matching method and path. Literal segments
+// are compared case-insensitively, as ARM does.
+func (p *Policy) Match(method, path string) (*Match, bool) {
+	return p.match("", method, path)
+}
+
+// MatchNamed returns the route named name if it matches method and path
+func (p *Policy) MatchNamed(name, method, path string) (*Match, bool) {
+	if name == "" {
+		return nil, false
+	}
+	return p.match(name, method, path)
+}
+
+func (p *Policy) match(name, method, path string) (*Match, bool) {
+	segments := splitPath(path)
+	for i := range p.Routes {
+		route := &p.Routes[i]
+		if name != "" && route.Name != name {
+			continue
+		}
+		if route.Method != "*" && route.Method != method {
+			continue
+		}
+		if params, ok := route.match(segments); ok {
+			return &Match{Route: route, Params: params}, true
+		}
+	}
+	return nil, false
+}
+
+// shadows tells whether every request other matches also matches r
+func (r *Route) shadows(other *Route) bool {
+	if r.Method != "*" && r.Method != other.Method {
+		return false
+	}
+	if len(r.segments) != len(other.segments) {
+		return false
+	}
+	for i, segment := range r.segments {
+		if _, ok := parameterName(segment); ok {
+			continue
+		}
+		if _, ok := parameterName(other.segments[i]); ok || !strings.EqualFold(segment, other.segments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range r.segments {
+		if name, ok := parameterName(segment); ok {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[name] = segments[i]
+		} else if !strings.EqualFold(segment, segments[i]) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// AuthorizationRequest returns the AuthorizationRequest of subject for the
+// request r matched by m
+func (m *Match) AuthorizationRequest(r *http.Request, subject client.SubjectAttributes) client.AuthorizationRequest {
+	var resourceId strings.Builder
+	for _, segment := range splitPath(m.Route.Resource) {
+		resourceId.WriteString("/")
+		if name, ok := parameterName(segment); ok {
+			resourceId.WriteString(m.Params[name])
+		} else {
+			resourceId.WriteString(segment)
+		}
+	}
+
+	authzReq := client.AuthorizationRequest{
+		Subject:  client.SubjectInfo{Attributes: subject},
+		Resource: client.ResourceInfo{Id: resourceId.String()},
+	}
+	for _, action := range m.Route.Actions {
+		authzReq.Actions = append(authzReq.Actions, client.ActionInfo{Id: action.Id, IsDataAction: action.IsDataAction})
+	}
+	for _, attr := range m.Route.Attributes {
+		var value string
+		switch attr.From {
+		case SourcePath:
+			value = m.Params[attr.Key]
+		case SourceQuery:
+			value = r.URL.Query().Get(attr.Key)
+		case SourceHeader:
+			value = r.Header.Get(attr.Key)
+		}
+		if value == "" {
+			continue
+		}
+		target := &authzReq.Resource.Attributes
+		if attr.Target == TargetEnvironment {
+			target = &authzReq.Environment.Attributes
+		}
+		if *target == nil {
+			*target = client.Attributes{}
+		}
+		(*target)[attr.Name] = value
+	}
+	return authzReq
+}
+
+// splitPath returns the segments of a path, without empty leading and
+// trailing segments
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// parameterName returns the name of a {name} segment
+func parameterName(segment string) (string, bool) {
+	match := parameter.FindStringSubmatch(segment)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// normalizeTemplate returns template with anonymous parameters and lower
+// case literals, so templates differing only by parameter names are equal
+func normalizeTemplate(template string) string {
+	segments := splitPath(template)
+	for i, segment := range segments {
+		if _, ok := parameterName(segment); ok {
+			segments[i] = "{}"
+		} else {
+			segments[i] = strings.ToLower(segment)
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,242 @@
+package routepolicy
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+const widget = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Contoso/widgets/w1"
+
+func TestLoad(t *testing.T) {
+	p, err := Load("testdata/policy.yaml")
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if len(p.Routes) != 3 {
+		t.Errorf("expected 3 routes but got %d", len(p.Routes))
+	}
+
+	if _, err := Parse([]byte(`{"routes":[{"method":"get","path":"/a/{id}","resource":"/a/{id}","actions":[{"id":"read"}]}]}`)); err != nil {
+		t.Errorf("expected a JSON policy to parse but got '%v'", err)
+	}
+
+	// the more specific routes go first
+	if _, err := Parse([]byte(`routes:
+- {method: GET, path: "/a/{id}/blobs", resource: "/a/{id}", actions: [{id: blobs/read}]}
+- {method: GET, path: "/a/{id}/{child}", resource: "/a/{id}", actions: [{id: read}]}
+- {method: "*", path: "/a/{id}/{child}", resource: "/a/{id}", actions: [{id: write}]}`)); err != nil {
+		t.Errorf("expected a policy without shadowed routes to parse but got '%v'", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		policy  string
+		wantErr string
+	}{
+		{
+			name:    "fail - unknown field",
+			policy:  `routes: [{method: GET, path: /a, resource: /a, actions: [{id: read}], extra: 1}]`,
+			wantErr: "error while parsing the policy",
+		},
+		{
+			name:    "fail - invalid method",
+			policy:  `routes: [{method: FETCH, path: /a, resource: /a, actions: [{id: read}]}]`,
+			wantErr: "route 0 (FETCH /a): method: FETCH is not valid",
+		},
+		{
+			name:    "fail - resource parameter not in path",
+			policy:  `routes: [{method: GET, path: "/a/{id}", resource: "/a/{other}", actions: [{id: read}]}]`,
+			wantErr: "route 0 (GET /a/{id}): resource: parameter other is not a parameter of the path",
+		},
+		{
+			name:    "fail - no actions",
+			policy:  `routes: [{method: GET, path: /a, resource: /a}]`,
+			wantErr: "route 0 (GET /a): need actions",
+		},
+		{
+			name:    "fail - invalid attribute source",
+			policy:  `routes: [{method: GET, path: /a, resource: /a, actions: [{id: read}], attributes: [{name: n, from: body, key: k}]}]`,
+			wantErr: "route 0 (GET /a): attribute n: from: body is not valid, need one of path, query, header",
+		},
+		{
+			name: "fail - duplicate route",
+			policy: `routes:
+- {method: GET, path: "/a/{id}", resource: "/a/{id}", actions: [{id: read}]}
+- {method: GET, path: "/A/{name}", resource: "/a/{name}", actions: [{id: read}]}`,
+			wantErr: "route 1 (GET /A/{name}): duplicates route 0",
+		},
+		{
+			name: "fail - shadowed by any method",
+			policy: `routes:
+- {method: "*", path: "/a/{id}", resource: "/a/{id}", actions: [{id: read}]}
+- {method: PUT, path: "/a/{id}", resource: "/a/{id}", actions: [{id: write}]}`,
+			wantErr: "route 1 (PUT /a/{id}): is shadowed by route 0 (* /a/{id})",
+		},
+		{
+			name: "fail - shadowed by a parameter",
+			policy: `routes:
+- {method: GET, path: "/a/{id}/{child}", resource: "/a/{id}", actions: [{id: read}]}
+- {method: GET, path: "/a/{id}/blobs", resource: "/a/{id}", actions: [{id: blobs/read}]}`,
+			wantErr: "route 1 (GET /a/{id}/blobs): is shadowed by route 0 (GET /a/{id}/{child})",
+		},
+		{
+			name: "fail - duplicate name",
+			policy: `routes:
+- {name: widgets, method: GET, path: /a, resource: /a, actions: [{id: read}]}
+- {name: widgets, method: PUT, path: /a, resource: /a, actions: [{id: write}]}`,
+			wantErr: "route 1 (PUT /a): name widgets duplicates route 0",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.policy))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("expected error to start with '%s' but got '%v'", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMatchAuthorizationRequest(t *testing.T) {
+	p, err := Load("testdata/policy.yaml")
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	subject := client.SubjectAttributes{ObjectId: "oid"}
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		target string
+		header http.Header
+		want   *client.AuthorizationRequest
+	}{
+		{
+			name:   "pass - read ignores the case of literals",
+			method: http.MethodGet,
+			target: strings.ToLower(widget),
+			want: &client.AuthorizationRequest{
+				Subject: client.SubjectInfo{Attributes: subject},
+				Actions: []client.ActionInfo{{Id: "Microsoft.Contoso/widgets/read"}},
+				// literals of the resource come from its template
+				Resource: client.ResourceInfo{Id: widget},
+			},
+		},
+		{
+			name:   "pass - write extracts query and header attributes",
+			method: http.MethodPut,
+			target: widget + "?sku=premium",
+			header: http.Header{"X-Ms-Client-Region": {"westus"}},
+			want: &client.AuthorizationRequest{
+				Subject:     client.SubjectInfo{Attributes: subject},
+				Actions:     []client.ActionInfo{{Id: "Microsoft.Contoso/widgets/write"}},
+				Resource:    client.ResourceInfo{Id: widget, Attributes: client.Attributes{"Microsoft.Contoso/widgets:sku": "premium"}},
+				Environment: client.EnvironmentInfo{Attributes: client.Attributes{"clientRegion": "westus"}},
+			},
+		},
+		{
+			name:   "pass - data action with a path attribute",
+			method: http.MethodGet,
+			target: widget + "/blobs/b1",
+			want: &client.AuthorizationRequest{
+				Subject:  client.SubjectInfo{Attributes: subject},
+				Actions:  []client.ActionInfo{{Id: "Microsoft.Contoso/widgets/blobs/read", IsDataAction: true}},
+				Resource: client.ResourceInfo{Id: widget, Attributes: client.Attributes{"Microsoft.Contoso/widgets/blobs:name": "b1"}},
+			},
+		},
+		{
+			name:   "pass - unmapped method",
+			method: http.MethodDelete,
+			target: widget,
+		},
+		{
+			name:   "pass - unmapped path",
+			method: http.MethodGet,
+			target: widget + "/other",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			match, ok := p.Match(r.Method, r.URL.Path)
+			if tt.want == nil {
+				if ok {
+					t.Errorf("expected no match but got %v", match.Route)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("expected a match")
+			}
+			got := match.AuthorizationRequest(r, subject)
+			if diff := cmp.Diff(tt.want, &got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("incorrect AuthorizationRequest: %v", diff)
+			}
+		})
+	}
+}
+
+func TestMatchNamed(t *testing.T) {
+	p, err := Parse([]byte(`routes:
+- {name: read, method: GET, path: "/a/{id}", resource: "/a/{id}", actions: [{id: a/read}]}
+- {name: any, method: "*", path: "/b/{id}", resource: "/b/{id}", actions: [{id: b/action}]}`))
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		route     string
+		method    string
+		path      string
+		wantMatch bool
+	}{
+		{
+			name:      "pass - named route",
+			route:     "read",
+			method:    "GET",
+			path:      "/a/1",
+			wantMatch: true,
+		},
+		{
+			name:   "fail - other route matches the path",
+			route:  "any",
+			method: "GET",
+			path:   "/a/1",
+		},
+		{
+			name:   "fail - method",
+			route:  "read",
+			method: "PUT",
+			path:   "/a/1",
+		},
+		{
+			name:   "fail - empty name",
+			method: "GET",
+			path:   "/a/1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			match, ok := p.MatchNamed(tt.route, tt.method, tt.path)
+			if ok != tt.wantMatch {
+				t.Fatalf("expected match to be '%t' but got '%t'", tt.wantMatch, ok)
+			}
+			if ok && match.Route.Name != tt.route {
+				t.Errorf("expected route '%s' but got '%s'", tt.route, match.Route.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/token"
+)
+
+// Headers ARM forwards to resource providers about the caller
+const (
+	HeaderClientPrincipalId  = "x-ms-client-principal-id"
+	HeaderClientObjectId     = "x-ms-client-object-id"
+	HeaderClientTenantId     = "x-ms-client-tenant-id"
+	HeaderClientAppId        = "x-ms-client-app-id"
+	HeaderARMSignedUserToken = "x-ms-arm-signed-user-token"
+)
+
+// SubjectSource yields the SubjectAttributes of the caller of a request,
+// whatever the ingress the caller came through
+type SubjectSource interface {
+	Subject(ctx context.Context) (SubjectAttributes, error)
+}
+
+// SubjectSourceFunc adapts a function to a SubjectSource
+type SubjectSourceFunc func(ctx context.Context) (SubjectAttributes, error)
+
+func (f SubjectSourceFunc) Subject(ctx context.Context) (SubjectAttributes, error) {
+	return f(ctx)
+}
+
+// tokenSubject derives the subject from the claims of a JWT, the way
+// CreateAuthorizationRequest does. The signature is not validated.
+type tokenSubject string
+
+var _ SubjectSource = tokenSubject("")
+
+// TokenSubject returns the SubjectSource of the subject of a raw JWT. The
+// token must have been validated by the caller.
+func TokenSubject(jwtToken string) SubjectSource {
+	return tokenSubject(jwtToken)
+}
+
+func (t tokenSubject) Subject(ctx context.Context) (SubjectAttributes, error) {
+	if strings.TrimSpace(string(t)) == "" {
+		return SubjectAttributes{}, fmt.Errorf("need token in creating SubjectAttributes")
+	}
+	tokenClaims, err := token.ExtractClaims(string(t))
+	if err != nil {
+		return SubjectAttributes{}, fmt.Errorf("error while parse the token, err: %w", err)
+	}
+	return subjectAttributesFromClaims(tokenClaims), nil
+}
+
+// armHeadersSubject derives the subject from the client principal headers
+// ARM forwards
+type armHeadersSubject http.Header
+
+var _ SubjectSource = armHeadersSubject{}
+
+// ARMHeadersSubject returns the SubjectSource of the caller described by
+// the client principal headers ARM forwards. The object id is read from
+// x-ms-client-object-id, or x-ms-client-principal-id when absent. These
+// headers carry no groups: the PDP expands them itself.
+func ARMHeadersSubject(header http.Header) SubjectSource {
+	return armHeadersSubject(header)
+}
+
+func (h armHeadersSubject) Subject(ctx context.Context) (SubjectAttributes, error) {
+	header := http.Header(h)
+	objectId := header.Get(HeaderClientObjectId)
+	if objectId == "" {
+		objectId = header.Get(HeaderClientPrincipalId)
+	}
+	if objectId == "" {
+		return SubjectAttributes{}, fmt.Errorf("need %s or %s header in creating SubjectAttributes", HeaderClientObjectId, HeaderClientPrincipalId)
+	}
+	return SubjectAttributes{
+		ObjectId:      objectId,
+		TenantId:      header.Get(HeaderClientTenantId),
+		ApplicationId: header.Get(HeaderClientAppId),
+		ClaimName:     GroupExpansion,
+	}, nil
+}
+
+// ARMSignedUserTokenSubject returns the SubjectSource of the caller of the
+// x-ms-arm-signed-user-token header. ARM validated the user token before
+// signing it; its signature is not validated again.
+func ARMSignedUserTokenSubject(header http.Header) SubjectSource {
+	return SubjectSourceFunc(func(ctx context.Context) (SubjectAttributes, error) {
+		signed := header.Get(HeaderARMSignedUserToken)
+		if signed == "" {
+			return SubjectAttributes{}, fmt.Errorf("need %s header in creating SubjectAttributes", HeaderARMSignedUserToken)
+		}
+		return tokenSubject(signed).Subject(ctx)
+	})
+}
+
+// ARMRequestSubject returns the SubjectSource of the caller of a request
+// forwarded by ARM: the signed user token when present, as it carries the
+// groups of the caller, the client principal headers otherwise.
+func ARMRequestSubject(header http.Header) SubjectSource {
+	if header.Get(HeaderARMSignedUserToken) != "" {
+		return ARMSignedUserTokenSubject(header)
+	}
+	return ARMHeadersSubject(header)
+}
+
+// NewAuthorizationRequest creates the AuthorizationRequest of the subject of
+// source for actions on resourceId
+func NewAuthorizationRequest(ctx context.Context, resourceId string, actions []string, source SubjectSource) (*AuthorizationRequest, error) {
+	if source == nil {
+		return nil, fmt.Errorf("need subject source in creating AuthorizationRequest")
+	}
+	subjectAttributes, err := source.Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newAuthorizationRequest(resourceId, actions, subjectAttributes), nil
+}
@@ -0,0 +1,120 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+func TestSubjectSources(t *testing.T) {
+	userToken, err := test.CreateTestToken("oid", &internal.Custom{ObjectId: "oid", Groups: []string{"group1"}})
+	if err != nil {
+		t.Fatalf("Error creating test token: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		source  SubjectSource
+		want    SubjectAttributes
+		wantErr string
+	}{
+		{
+			name:   "pass - raw token",
+			source: TokenSubject(userToken),
+			want:   SubjectAttributes{ObjectId: "oid", Groups: []string{"group1"}},
+		},
+		{
+			name:    "fail - empty token",
+			source:  TokenSubject(""),
+			wantErr: "need token in creating SubjectAttributes",
+		},
+		{
+			name:    "fail - invalid token",
+			source:  TokenSubject("invalid"),
+			wantErr: "error while parse the token",
+		},
+		{
+			name: "pass - ARM headers prefer the object id",
+			source: ARMHeadersSubject(http.Header{
+				http.CanonicalHeaderKey(HeaderClientPrincipalId): {"puid"},
+				http.CanonicalHeaderKey(HeaderClientObjectId):    {"oid"},
+				http.CanonicalHeaderKey(HeaderClientTenantId):    {"tid"},
+				http.CanonicalHeaderKey(HeaderClientAppId):       {"appid"},
+			}),
+			want: SubjectAttributes{ObjectId: "oid", TenantId: "tid", ApplicationId: "appid", ClaimName: GroupExpansion},
+		},
+		{
+			name:   "pass - ARM headers fall back to the principal id",
+			source: ARMHeadersSubject(http.Header{http.CanonicalHeaderKey(HeaderClientPrincipalId): {"pid"}}),
+			want:   SubjectAttributes{ObjectId: "pid", ClaimName: GroupExpansion},
+		},
+		{
+			name:    "fail - ARM headers without object id",
+			source:  ARMHeadersSubject(http.Header{}),
+			wantErr: "need x-ms-client-object-id or x-ms-client-principal-id header in creating SubjectAttributes",
+		},
+		{
+			name:    "fail - no signed user token",
+			source:  ARMSignedUserTokenSubject(http.Header{}),
+			wantErr: "need x-ms-arm-signed-user-token header in creating SubjectAttributes",
+		},
+		{
+			name: "pass - ARM request prefers the signed user token",
+			source: ARMRequestSubject(http.Header{
+				http.CanonicalHeaderKey(HeaderARMSignedUserToken): {userToken},
+				http.CanonicalHeaderKey(HeaderClientObjectId):     {"other"},
+			}),
+			want: SubjectAttributes{ObjectId: "oid", Groups: []string{"group1"}},
+		},
+		{
+			name:   "pass - ARM request without signed user token",
+			source: ARMRequestSubject(http.Header{http.CanonicalHeaderKey(HeaderClientObjectId): {"oid"}}),
+			want:   SubjectAttributes{ObjectId: "oid", ClaimName: GroupExpansion},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.source.Subject(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error to start with '%s' but got '%v'", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("incorrect subject: %v", diff)
+			}
+		})
+	}
+}
+
+func TestNewAuthorizationRequest(t *testing.T) {
+	header := http.Header{http.CanonicalHeaderKey(HeaderClientObjectId): {"oid"}}
+	got, err := NewAuthorizationRequest(context.Background(), "/subscriptions/sub", []string{"read"}, ARMRequestSubject(header))
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	want := &AuthorizationRequest{
+		Subject:  SubjectInfo{Attributes: SubjectAttributes{ObjectId: "oid", ClaimName: GroupExpansion}},
+		Actions:  []ActionInfo{{Id: "read"}},
+		Resource: ResourceInfo{Id: "/subscriptions/sub"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("incorrect AuthorizationRequest: %v", diff)
+	}
+
+	if _, err := NewAuthorizationRequest(context.Background(), "/subscriptions/sub", []string{"read"}, nil); err == nil {
+		t.Error("expected an error for a nil subject source")
+	}
+}
@@ -0,0 +1,200 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// instrumentationName is the name reported to the OpenTelemetry tracer and meter
+const instrumentationName = "github.com/Azure/checkaccess-v2-go-sdk/client"
+
+// Span attribute keys recorded by the client
+const (
+	attrEndpoint           = attribute.Key("checkaccess.endpoint")
+	attrRegion             = attribute.Key("checkaccess.region")
+	attrActionCount        = attribute.Key("checkaccess.action_count")
+	attrRetryCount         = attribute.Key("checkaccess.retry_count")
+	attrCacheHit           = attribute.Key("checkaccess.cache_hit")
+	attrDecisionAllowed    = attribute.Key("checkaccess.decisions.allowed")
+	attrDecisionNotAllowed = attribute.Key("checkaccess.decisions.not_allowed")
+	attrDecisionDenied     = attribute.Key("checkaccess.decisions.denied")
+	attrAccessDecision     = attribute.Key("checkaccess.access_decision")
+)
+
+// telemetry holds the OpenTelemetry instruments used by remotePDPClient
+type telemetry struct {
+	tracer   trace.Tracer
+	latency  metric.Float64Histogram
+	allowed  metric.Int64Counter
+	denied   metric.Int64Counter
+	failures metric.Int64Counter
+	// attrs are recorded on every span and measurement of the client
+	attrs []attribute.KeyValue
+}
+
+// newTelemetry creates the instruments from the given providers. The global
+// providers are used when tp or mp are nil.
+func newTelemetry(endpoint string, tp trace.TracerProvider, mp metric.MeterProvider) (*telemetry, error) {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName, metric.WithInstrumentationVersion(version))
+
+	latency, err := meter.Float64Histogram("checkaccess.client.duration",
+		metric.WithDescription("Duration of CheckAccess calls"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := meter.Int64Counter("checkaccess.client.decisions.allowed",
+		metric.WithDescription("Number of actions the PDP allowed"))
+	if err != nil {
+		return nil, err
+	}
+	denied, err := meter.Int64Counter("checkaccess.client.decisions.denied",
+		metric.WithDescription("Number of actions the PDP did not allow, by access decision"))
+	if err != nil {
+		return nil, err
+	}
+	failures, err := meter.Int64Counter("checkaccess.client.errors",
+		metric.WithDescription("Number of CheckAccess calls that returned an error"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &telemetry{
+		tracer:   tp.Tracer(instrumentationName, trace.WithInstrumentationVersion(version)),
+		latency:  latency,
+		allowed:  allowed,
+		denied:   denied,
+		failures: failures,
+		attrs: []attribute.KeyValue{
+			attrEndpoint.String(endpoint),
+			attrRegion.String(regionFromEndpoint(endpoint)),
+		},
+	}, nil
+}
+
+// startCheckAccess starts the span of a CheckAccess call
+func (t *telemetry) startCheckAccess(ctx context.Context, authzReq AuthorizationRequest) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "RemotePDPClient.CheckAccess",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.attrs...),
+		trace.WithAttributes(attrActionCount.Int(len(authzReq.Actions))))
+}
+
+// endCheckAccess records the outcome of a CheckAccess call on its span and
+// on the metric instruments, then ends the span.
+func (t *telemetry) endCheckAccess(ctx context.Context, span trace.Span, start time.Time, attempts int32, cacheHit bool, res *AuthorizationDecisionResponse, err error) {
+	defer span.End()
+
+	span.SetAttributes(attrCacheHit.Bool(cacheHit))
+	if attempts > 1 {
+		span.SetAttributes(attrRetryCount.Int(int(attempts - 1)))
+	} else {
+		span.SetAttributes(attrRetryCount.Int(0))
+	}
+	t.latency.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(t.attrs...))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		t.failures.Add(ctx, 1, metric.WithAttributes(t.attrs...))
+		return
+	}
+
+	var allowed, notAllowed, denied int
+	for _, decision := range res.Value {
+		switch decision.AccessDecision {
+		case Allowed:
+			allowed++
+		case NotAllowed:
+			notAllowed++
+		case Denied:
+			denied++
+		}
+	}
+	span.SetAttributes(
+		attrDecisionAllowed.Int(allowed),
+		attrDecisionNotAllowed.Int(notAllowed),
+		attrDecisionDenied.Int(denied),
+	)
+	if allowed > 0 {
+		t.allowed.Add(ctx, int64(allowed), metric.WithAttributes(t.attrs...))
+	}
+	if notAllowed > 0 {
+		t.denied.Add(ctx, int64(notAllowed), metric.WithAttributes(append(t.attrs, attrAccessDecision.String(string(NotAllowed)))...))
+	}
+	if denied > 0 {
+		t.denied.Add(ctx, int64(denied), metric.WithAttributes(append(t.attrs, attrAccessDecision.String(string(Denied)))...))
+	}
+}
+
+// startCreateAuthorizationRequest starts the span of a CreateAuthorizationRequest call
+func (t *telemetry) startCreateAuthorizationRequest(actionCount int) trace.Span {
+	_, span := t.tracer.Start(context.Background(), "RemotePDPClient.CreateAuthorizationRequest",
+		trace.WithAttributes(attrActionCount.Int(actionCount)))
+	return span
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// regionFromEndpoint returns the region of a regional PDP endpoint, e.g.
+// "westus" for https://westus.authorization.azure.net/...
+func regionFromEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	host := u.Hostname()
+	region, _, found := strings.Cut(host, ".")
+	if !found {
+		return ""
+	}
+	return region
+}
+
+// attemptCounterKey is the context key of the attempt counter of a request
+type attemptCounterKey struct{}
+
+// withAttemptCounter returns a context carrying a counter that
+// attemptCounterPolicy increments on every try of the request.
+func withAttemptCounter(ctx context.Context) (context.Context, *int32) {
+	var attempts int32
+	return context.WithValue(ctx, attemptCounterKey{}, &attempts), &attempts
+}
+
+// attemptCounterPolicy counts how many times the retry policy sent a request
+type attemptCounterPolicy struct{}
+
+func (attemptCounterPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if attempts, ok := req.Raw().Context().Value(attemptCounterKey{}).(*int32); ok {
+		atomic.AddInt32(attempts, 1)
+	}
+	return req.Next()
+}
@@ -0,0 +1,175 @@
+package client
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+func TestCheckAccessTelemetry(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	scope := "https://authorization.azure.net/.default"
+	body := `{"value":[
+		{"actionId":"read","accessDecision":"Allowed"},
+		{"actionId":"write","accessDecision":"NotAllowed"},
+		{"actionId":"delete","accessDecision":"Denied"}]}`
+
+	for _, tt := range []struct {
+		name             string
+		returnedHttpCode int
+		body             string
+		wantStatus       codes.Code
+		wantAttributes   []attribute.KeyValue
+		wantMetrics      map[string]int64
+	}{
+		{
+			name:             "success - span carries decision counts",
+			returnedHttpCode: http.StatusOK,
+			body:             body,
+			wantStatus:       codes.Unset,
+			wantAttributes: []attribute.KeyValue{
+				attrEndpoint.String(endpoint),
+				attrRegion.String("westus"),
+				attrActionCount.Int(3),
+				attrRetryCount.Int(0),
+				attrDecisionAllowed.Int(1),
+				attrDecisionNotAllowed.Int(1),
+				attrDecisionDenied.Int(1),
+			},
+			wantMetrics: map[string]int64{
+				"checkaccess.client.decisions.allowed": 1,
+				"checkaccess.client.decisions.denied":  2,
+			},
+		},
+		{
+			name:             "fail - span records the error",
+			returnedHttpCode: http.StatusUnauthorized,
+			body:             `{"statusCode":401,"message":"unauthorized"}`,
+			wantStatus:       codes.Error,
+			wantAttributes: []attribute.KeyValue{
+				attrEndpoint.String(endpoint),
+				attrRegion.String("westus"),
+				attrActionCount.Int(3),
+				attrRetryCount.Int(0),
+			},
+			wantMetrics: map[string]int64{
+				"checkaccess.client.errors": 1,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := tracetest.NewSpanRecorder()
+			reader := sdkmetric.NewManualReader()
+
+			client, err := NewRemotePDPClientWithOptions(endpoint, scope, test.FakeCredential{}, &ClientOptions{
+				ClientOptions: azcore.ClientOptions{
+					Transport: test.CreateTransport(tt.returnedHttpCode, tt.body),
+				},
+				TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+				MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+			})
+			if err != nil {
+				t.Fatalf("Unable to create a new PDP client: %v", err)
+			}
+
+			_, _ = client.CheckAccess(context.Background(), AuthorizationRequest{
+				Actions: []ActionInfo{{Id: "read"}, {Id: "write"}, {Id: "delete"}},
+			})
+
+			ended := spans.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("expected 1 span but got %d", len(ended))
+			}
+			if ended[0].Status().Code != tt.wantStatus {
+				t.Errorf("expected span status %v but got %v", tt.wantStatus, ended[0].Status().Code)
+			}
+			got := attribute.NewSet(ended[0].Attributes()...)
+			for _, want := range tt.wantAttributes {
+				if v, ok := got.Value(want.Key); !ok || v != want.Value {
+					t.Errorf("expected attribute %s=%v but got %v", want.Key, want.Value.Emit(), v.Emit())
+				}
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatalf("Unable to collect metrics: %v", err)
+			}
+			sums := map[string]int64{}
+			var latencyCount uint64
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					switch data := m.Data.(type) {
+					case metricdata.Sum[int64]:
+						for _, dp := range data.DataPoints {
+							sums[m.Name] += dp.Value
+						}
+					case metricdata.Histogram[float64]:
+						for _, dp := range data.DataPoints {
+							latencyCount += dp.Count
+						}
+					}
+				}
+			}
+			if latencyCount != 1 {
+				t.Errorf("expected 1 latency measurement but got %d", latencyCount)
+			}
+			for name, want := range tt.wantMetrics {
+				if sums[name] != want {
+					t.Errorf("expected metric %s to be %d but got %d", name, want, sums[name])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckAccessTelemetryRetryCount(t *testing.T) {
+	endpoint := "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	spans := tracetest.NewSpanRecorder()
+
+	client, err := NewRemotePDPClientWithOptions(endpoint, "https://authorization.azure.net/.default", test.FakeCredential{}, &ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: test.CreateTransport(http.StatusServiceUnavailable, `{"statusCode":503}`),
+			Retry:     policy.RetryOptions{MaxRetries: 2, RetryDelay: 1, MaxRetryDelay: 1},
+		},
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+	})
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+
+	if _, err := client.CheckAccess(context.Background(), AuthorizationRequest{}); err == nil {
+		t.Errorf("expected error to be 'non-nil' but got 'nil'")
+	}
+
+	got := attribute.NewSet(spans.Ended()[0].Attributes()...)
+	if v, _ := got.Value(attrRetryCount); v.AsInt64() != 2 {
+		t.Errorf("expected retry count to be 2 but got %d", v.AsInt64())
+	}
+}
+
+func TestRegionFromEndpoint(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess": "westus",
+		"https://localhost/checkAccess": "",
+		"://invalid":                    "",
+	} {
+		if got := regionFromEndpoint(endpoint); got != want {
+			t.Errorf("expected region of %s to be %q but got %q", endpoint, want, got)
+		}
+	}
+}
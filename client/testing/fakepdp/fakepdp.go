@@ -0,0 +1,104 @@
+package fakepdp
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+// Package fakepdp is an in-memory RemotePDPClient for the tests of the code
+// built on the client, deciding from a table of actions instead of calling a
+// PDP server.
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// this asserts that &Client{} would always implement client.RemotePDPClient
+var _ client.RemotePDPClient = &Client{}
+
+// Client is a RemotePDPClient recording its requests. Its fields must not be
+// changed once it is in use.
+type Client struct {
+	// Decisions are the decisions of the actions, by action ID
+	Decisions map[string]client.AccessDecision
+	// Default is the decision of the actions missing from Decisions,
+	// client.NotAllowed when empty
+	Default client.AccessDecision
+	// Err, if set, is returned by every CheckAccess call
+	Err error
+	// CheckAccessFunc, if set, answers the CheckAccess calls instead of
+	// Decisions, Default and Err
+	CheckAccessFunc func(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error)
+
+	mu       sync.Mutex
+	requests []client.AuthorizationRequest
+}
+
+// New returns a Client answering decision for every action
+func New(decision client.AccessDecision) *Client {
+	return &Client{Default: decision}
+}
+
+// CheckAccess records authzReq and answers it
+func (c *Client) CheckAccess(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+	c.mu.Lock()
+	c.requests = append(c.requests, authzReq)
+	c.mu.Unlock()
+
+	if c.CheckAccessFunc != nil {
+		return c.CheckAccessFunc(ctx, authzReq)
+	}
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return Respond(authzReq, c.Decisions, c.Default), nil
+}
+
+// CreateAuthorizationRequest builds the request from the claims of jwtToken,
+// as the remote client does
+func (c *Client) CreateAuthorizationRequest(resourceId string, actions []string, jwtToken string) (*client.AuthorizationRequest, error) {
+	return client.NewAuthorizationRequest(context.Background(), resourceId, actions, client.TokenSubject(jwtToken))
+}
+
+// Requests returns the requests received so far
+func (c *Client) Requests() []client.AuthorizationRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]client.AuthorizationRequest(nil), c.requests...)
+}
+
+// Calls returns the number of CheckAccess calls received so far
+func (c *Client) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.requests)
+}
+
+// Respond returns the response deciding each action of authzReq from
+// decisions, or fallback, client.NotAllowed when empty, for the missing
+// actions. Action IDs are compared case-insensitively.
+func Respond(authzReq client.AuthorizationRequest, decisions map[string]client.AccessDecision, fallback client.AccessDecision) *client.AuthorizationDecisionResponse {
+	if fallback == "" {
+		fallback = client.NotAllowed
+	}
+	res := &client.AuthorizationDecisionResponse{}
+	for _, action := range authzReq.Actions {
+		decision, ok := decisions[action.Id]
+		if !ok {
+			decision = fallback
+			for id, d := range decisions {
+				if strings.EqualFold(id, action.Id) {
+					decision = d
+					break
+				}
+			}
+		}
+		res.Value = append(res.Value, client.AuthorizationDecision{
+			ActionId:       action.Id,
+			AccessDecision: decision,
+			IsDataAction:   action.IsDataAction,
+		})
+	}
+	return res
+}
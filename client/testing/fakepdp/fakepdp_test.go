@@ -0,0 +1,71 @@
+package fakepdp
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+func TestClient(t *testing.T) {
+	authzReq := client.AuthorizationRequest{
+		Actions: []client.ActionInfo{{Id: "read"}, {Id: "WRITE"}, {Id: "blobs/read", IsDataAction: true}},
+	}
+
+	for _, tt := range []struct {
+		name    string
+		client  *Client
+		want    *client.AuthorizationDecisionResponse
+		wantErr error
+	}{
+		{
+			name:   "pass - decisions and default",
+			client: &Client{Decisions: map[string]client.AccessDecision{"read": client.Allowed, "write": client.Denied}},
+			want: &client.AuthorizationDecisionResponse{Value: []client.AuthorizationDecision{
+				{ActionId: "read", AccessDecision: client.Allowed},
+				{ActionId: "WRITE", AccessDecision: client.Denied},
+				{ActionId: "blobs/read", AccessDecision: client.NotAllowed, IsDataAction: true},
+			}},
+		},
+		{
+			name:   "pass - every action",
+			client: New(client.Allowed),
+			want: &client.AuthorizationDecisionResponse{Value: []client.AuthorizationDecision{
+				{ActionId: "read", AccessDecision: client.Allowed},
+				{ActionId: "WRITE", AccessDecision: client.Allowed},
+				{ActionId: "blobs/read", AccessDecision: client.Allowed, IsDataAction: true},
+			}},
+		},
+		{
+			name:    "fail - error",
+			client:  &Client{Err: errors.New("unavailable")},
+			wantErr: errors.New("unavailable"),
+		},
+		{
+			name: "pass - func",
+			client: &Client{CheckAccessFunc: func(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+				return &client.AuthorizationDecisionResponse{}, nil
+			}},
+			want: &client.AuthorizationDecisionResponse{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.client.CheckAccess(context.Background(), authzReq)
+			if (err == nil) != (tt.wantErr == nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("expected error to be '%v' but got '%v'", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, res); diff != "" {
+				t.Errorf("incorrect response: %v", diff)
+			}
+			if tt.client.Calls() != 1 || len(tt.client.Requests()) != 1 {
+				t.Errorf("expected 1 recorded call but got %d", tt.client.Calls())
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package fault
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// this asserts that &Transport{} would always implement policy.Transporter
+var _ policy.Transporter = &Transport{}
+
+// Fault answers req, possibly by calling next
+type Fault func(req *http.Request, next policy.Transporter) (*http.Response, error)
+
+// Schedule picks the fault applied to each request
+type Schedule interface {
+	Next(req *http.Request) Fault
+}
+
+// Transport is a policy.Transporter injecting the faults of a schedule in
+// front of an inner transport. It can be set as the Transport of the
+// azcore.ClientOptions given to NewRemotePDPClient.
+type Transport struct {
+	schedule Schedule
+	inner    policy.Transporter
+
+	mu       sync.Mutex
+	requests int
+}
+
+// NewTransport returns a Transport applying schedule to requests sent to
+// inner, http.DefaultClient when nil
+func NewTransport(schedule Schedule, inner policy.Transporter) *Transport {
+	if inner == nil {
+		inner = http.DefaultClient
+	}
+	return &Transport{schedule: schedule, inner: inner}
+}
+
+// Do applies the next fault of the schedule to req
+func (t *Transport) Do(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests++
+	fault := t.schedule.Next(req)
+	t.mu.Unlock()
+
+	if fault == nil {
+		fault = Pass()
+	}
+	return fault(req, t.inner)
+}
+
+// Requests returns the number of requests the transport received
+func (t *Transport) Requests() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.requests
+}
+
+// sequence applies faults in order, then passes requests through
+type sequence struct {
+	faults []Fault
+	next   int
+}
+
+// Sequence returns a Schedule applying faults to the first requests, in
+// order, and passing the following requests through
+func Sequence(faults ...Fault) Schedule {
+	return &sequence{faults: faults}
+}
+
+func (s *sequence) Next(req *http.Request) Fault {
+	if s.next >= len(s.faults) {
+		return Pass()
+	}
+	s.next++
+	return s.faults[s.next-1]
+}
+
+// Repeat returns n copies of fault, e.g. to script a burst in a Sequence
+func Repeat(fault Fault, n int) []Fault {
+	faults := make([]Fault, n)
+	for i := range faults {
+		faults[i] = fault
+	}
+	return faults
+}
+
+// Choice is a fault applied with a probability
+type Choice struct {
+	Probability float64
+	Fault       Fault
+}
+
+// random applies each choice with its probability
+type random struct {
+	rand    *rand.Rand
+	choices []Choice
+}
+
+// Random returns a Schedule applying each choice with its probability and
+// passing the remaining requests through. The probabilities must add up to
+// at most 1. The same seed always yields the same schedule.
+func Random(seed uint64, choices ...Choice) Schedule {
+	return &random{rand: rand.New(rand.NewPCG(seed, seed)), choices: choices}
+}
+
+func (r *random) Next(req *http.Request) Fault {
+	n := r.rand.Float64()
+	for _, choice := range r.choices {
+		if n < choice.Probability {
+			return choice.Fault
+		}
+		n -= choice.Probability
+	}
+	return Pass()
+}
+
+// Pass sends the request to the inner transport
+func Pass() Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		return next.Do(req)
+	}
+}
+
+// ConnectionReset fails the request as if the server reset the connection
+func ConnectionReset() Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	}
+}
+
+// SlowBody delays the first read of the inner transport's response body by
+// delay, or until the request's context is done
+func SlowBody(delay time.Duration) Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		res, err := next.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		res.Body = &slowBody{body: res.Body, delay: delay, done: req.Context().Done()}
+		return res, nil
+	}
+}
+
+// TooManyRequests answers 429 with a Retry-After header of retryAfter
+func TooManyRequests(retryAfter time.Duration) Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		res := newResponse(req, http.StatusTooManyRequests, "application/json",
+			fmt.Sprintf(`{"statusCode":%d,"message":"Too many requests"}`, http.StatusTooManyRequests))
+		res.Header.Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		return res, nil
+	}
+}
+
+// ServiceUnavailable answers 503 with a JSON error body
+func ServiceUnavailable() Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		return newResponse(req, http.StatusServiceUnavailable, "application/json",
+			fmt.Sprintf(`{"statusCode":%d,"message":"Service unavailable"}`, http.StatusServiceUnavailable)), nil
+	}
+}
+
+// NonJSONError answers statusCode with a body that isn't JSON, as returned
+// by proxies and load balancers
+func NonJSONError(statusCode int, body string) Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		return newResponse(req, statusCode, "text/html", body), nil
+	}
+}
+
+// TruncatedJSON answers with the inner transport's response, its body cut
+// in half
+func TruncatedJSON() Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		res, err := next.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = body[:len(body)/2]
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		res.ContentLength = int64(len(body))
+		res.Header.Del("Content-Length")
+		return res, nil
+	}
+}
+
+// Respond answers with statusCode and a JSON body, without calling the
+// inner transport. It is handy to script the healthy answers of a Sequence.
+func Respond(statusCode int, body string) Fault {
+	return func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		return newResponse(req, statusCode, "application/json", body), nil
+	}
+}
+
+func newResponse(req *http.Request, statusCode int, contentType, body string) *http.Response {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	return &http.Response{
+		StatusCode:    statusCode,
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// slowBody delays its first read
+type slowBody struct {
+	body    io.ReadCloser
+	delay   time.Duration
+	done    <-chan struct{}
+	delayed bool
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if !b.delayed {
+		b.delayed = true
+		timer := time.NewTimer(b.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-b.done:
+			return 0, fmt.Errorf("fault: body read canceled")
+		}
+	}
+	return b.body.Read(p)
+}
+
+func (b *slowBody) Close() error {
+	return b.body.Close()
+}
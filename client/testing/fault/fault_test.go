@@ -0,0 +1,152 @@
+package fault
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+const (
+	endpoint = "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+	scope    = "https://authorization.azure.net/.default"
+	allowed  = `{"value":[{"actionId":"read","accessDecision":"Allowed"}]}`
+)
+
+func TestTransportWithClient(t *testing.T) {
+	for _, tt := range []struct {
+		desc         string
+		schedule     Schedule
+		maxRetries   int32
+		timeout      time.Duration
+		wantRequests int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{
+			desc:         "503 burst is retried until the PDP recovers",
+			schedule:     Sequence(append(Repeat(ServiceUnavailable(), 2), Pass())...),
+			maxRetries:   3,
+			wantRequests: 3,
+		},
+		{
+			desc:         "429 with Retry-After is retried",
+			schedule:     Sequence(TooManyRequests(0), Pass()),
+			maxRetries:   3,
+			wantRequests: 2,
+		},
+		{
+			desc:         "connection reset is retried",
+			schedule:     Sequence(ConnectionReset(), Pass()),
+			maxRetries:   3,
+			wantRequests: 2,
+		},
+		{
+			desc:         "503 burst longer than the retries fails",
+			schedule:     Sequence(Repeat(ServiceUnavailable(), 5)...),
+			maxRetries:   2,
+			wantRequests: 3,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantErr:      true,
+		},
+		{
+			desc:         "non-JSON error body still yields a ResponseError",
+			schedule:     Sequence(NonJSONError(http.StatusBadGateway, "<html>Bad Gateway</html>")),
+			maxRetries:   -1,
+			wantRequests: 1,
+			wantStatus:   http.StatusBadGateway,
+			wantErr:      true,
+		},
+		{
+			desc:         "truncated JSON fails decoding",
+			schedule:     Sequence(TruncatedJSON()),
+			maxRetries:   -1,
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			desc:         "slow body fails past the deadline",
+			schedule:     Sequence(SlowBody(time.Minute)),
+			maxRetries:   -1,
+			timeout:      50 * time.Millisecond,
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			transport := NewTransport(tt.schedule, test.CreateTransport(http.StatusOK, allowed))
+			pdp, err := client.NewRemotePDPClient(endpoint, scope, test.FakeCredential{}, &azcore.ClientOptions{
+				Transport: transport,
+				Retry: policy.RetryOptions{
+					MaxRetries:    tt.maxRetries,
+					RetryDelay:    time.Millisecond,
+					MaxRetryDelay: time.Millisecond,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Unable to create a new PDP client: %v", err)
+			}
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			res, err := pdp.CheckAccess(ctx, client.AuthorizationRequest{Actions: []client.ActionInfo{{Id: "read"}}})
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected error to be %v but got '%v'", tt.wantErr, err)
+			}
+			if !tt.wantErr && (len(res.Value) != 1 || res.Value[0].AccessDecision != client.Allowed) {
+				t.Errorf("expected the read action to be allowed but got %v", res)
+			}
+			if tt.wantStatus != 0 {
+				var responseErr *azcore.ResponseError
+				if !errors.As(err, &responseErr) || responseErr.StatusCode != tt.wantStatus {
+					t.Errorf("expected a ResponseError with status %d but got '%v'", tt.wantStatus, err)
+				}
+			}
+			if transport.Requests() != tt.wantRequests {
+				t.Errorf("expected %d requests but got %d", tt.wantRequests, transport.Requests())
+			}
+		})
+	}
+}
+
+func TestRandom(t *testing.T) {
+	reset := ConnectionReset()
+	next := test.CreateTransport(http.StatusOK, allowed)
+	faults := func(seed uint64) []bool {
+		schedule := Random(seed, Choice{Probability: 0.2, Fault: reset})
+		injected := make([]bool, 1000)
+		for i := range injected {
+			_, err := schedule.Next(nil)(nil, next)
+			injected[i] = err != nil
+		}
+		return injected
+	}
+
+	first, second := faults(42), faults(42)
+	count := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected the same seed to yield the same schedule")
+		}
+		if first[i] {
+			count++
+		}
+	}
+	if count < 150 || count > 250 {
+		t.Errorf("expected about 20%% of faults but got %d/1000", count)
+	}
+}
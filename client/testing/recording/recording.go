@@ -0,0 +1,457 @@
+package recording
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// this asserts that &Transport{} would always implement policy.Transporter
+var _ policy.Transporter = &Transport{}
+
+// Mode tells whether a Transport records or replays exchanges
+type Mode string
+
+// Mode possible values
+const (
+	// ModeRecord sends requests to the real transport and records the exchanges
+	ModeRecord Mode = "Record"
+	// ModeReplay answers requests from the recorded exchanges only
+	ModeReplay Mode = "Replay"
+)
+
+// Redacted replaces scrubbed header values in recorded fixtures, and
+// prefixes the pseudonyms of scrubbed JSON values
+const Redacted = "REDACTED"
+
+// redactedPrefix starts the pseudonyms of scrubbed JSON values
+const redactedPrefix = Redacted + ":"
+
+// Redact returns the pseudonym of a scrubbed JSON value: Redacted and the
+// HMAC-SHA256 of value with key. Equal values have equal pseudonyms for a
+// given key, and the values can't be recovered from a fixture without it.
+func Redact(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return redactedPrefix + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// DefaultScrubbedHeaders are the headers whose values are never recorded
+var DefaultScrubbedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "x-ms-arm-signed-user-token"}
+
+// DefaultScrubbedJSONFields are the JSON fields, at any depth and compared
+// case-insensitively, holding personal data in PDP requests and responses.
+// As they match at any depth, generic names such as "name" don't belong here.
+var DefaultScrubbedJSONFields = []string{
+	"ObjectId", "Groups", "tid", "puid", "altsecid", "idp", "iss",
+	"ApplicationId", "principalId", "upn", "email",
+}
+
+// Fixture is the content of a recording file
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the scrubbed form of a request
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the scrubbed form of a response
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Options configures a Transport
+type Options struct {
+	// Mode is ModeRecord or ModeReplay
+	Mode Mode
+
+	// FixturePath is the file the exchanges are saved to and replayed from
+	FixturePath string
+
+	// Transport sends the requests in ModeRecord, http.DefaultClient when nil
+	Transport policy.Transporter
+
+	// ScrubbedHeaders are recorded as Redacted, DefaultScrubbedHeaders when nil
+	ScrubbedHeaders []string
+
+	// ScrubbedJSONFields are recorded by Redact, DefaultScrubbedJSONFields when nil
+	ScrubbedJSONFields []string
+
+	// Key is the HMAC key of the pseudonyms of the scrubbed JSON values, a
+	// random key of the transport when nil. It is never saved: replay
+	// doesn't need the key of the recording, see Transport.
+	Key []byte
+}
+
+// Transport is a policy.Transporter recording exchanges with a real server to
+// a fixture file, or replaying them from it. Requests are matched on method,
+// URL and JSON body, after scrubbing and normalization. As the keys of the
+// recording and of the replay may differ, a scrubbed value matches the first
+// recorded pseudonym it is compared with, and from then on only that one:
+// replay keeps the subjects of a fixture apart without knowing them. Only
+// with the key of the recording does replay know which subject is which.
+type Transport struct {
+	options Options
+	headers map[string]bool
+	fields  map[string]bool
+
+	mu       sync.Mutex
+	fixture  Fixture
+	replayed []bool
+	// bindings map the recorded pseudonyms to the incoming ones, and bound
+	// the incoming pseudonyms to the recorded ones
+	bindings map[string]string
+	bound    map[string]string
+}
+
+// NewTransport returns a Transport. In ModeReplay, the fixture is loaded
+// from options.FixturePath.
+func NewTransport(options Options) (*Transport, error) {
+	if options.Mode != ModeRecord && options.Mode != ModeReplay {
+		return nil, fmt.Errorf("mode: %s is not valid, need a valid mode in creating recording transport", options.Mode)
+	}
+	if strings.TrimSpace(options.FixturePath) == "" {
+		return nil, fmt.Errorf("need a fixture path in creating recording transport")
+	}
+	if options.Transport == nil {
+		options.Transport = http.DefaultClient
+	}
+	if options.ScrubbedHeaders == nil {
+		options.ScrubbedHeaders = DefaultScrubbedHeaders
+	}
+	if options.ScrubbedJSONFields == nil {
+		options.ScrubbedJSONFields = DefaultScrubbedJSONFields
+	}
+	if options.Key == nil {
+		options.Key = make([]byte, 32)
+		if _, err := rand.Read(options.Key); err != nil {
+			return nil, fmt.Errorf("error while generating the pseudonym key, err: %w", err)
+		}
+	}
+
+	t := &Transport{
+		options:  options,
+		headers:  map[string]bool{},
+		fields:   map[string]bool{},
+		bindings: map[string]string{},
+		bound:    map[string]string{},
+	}
+	for _, h := range options.ScrubbedHeaders {
+		t.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, f := range options.ScrubbedJSONFields {
+		t.fields[strings.ToLower(f)] = true
+	}
+
+	if options.Mode == ModeReplay {
+		content, err := os.ReadFile(options.FixturePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &t.fixture); err != nil {
+			return nil, fmt.Errorf("error while parsing fixture %s, err: %w", options.FixturePath, err)
+		}
+		t.replayed = make([]bool, len(t.fixture.Interactions))
+	}
+	return t, nil
+}
+
+// Do records or replays req
+func (t *Transport) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := t.recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.options.Mode == ModeReplay {
+		return t.replay(req, recorded)
+	}
+
+	res, err := t.options.Transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fixture.Interactions = append(t.fixture.Interactions, Interaction{
+		Request: *recorded,
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     t.scrubHeader(res.Header),
+			Body:       t.scrubBody(body),
+		},
+	})
+	return res, nil
+}
+
+// Save writes the recorded exchanges to the fixture file
+func (t *Transport) Save() error {
+	if t.options.Mode != ModeRecord {
+		return fmt.Errorf("only a recording transport can be saved")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	content, err := json.MarshalIndent(t.fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.options.FixturePath, append(content, '\n'), 0o644)
+}
+
+// Unused returns the recorded requests that were never replayed
+func (t *Transport) Unused() []RecordedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var unused []RecordedRequest
+	for i, replayed := range t.replayed {
+		if !replayed {
+			unused = append(unused, t.fixture.Interactions[i].Request)
+		}
+	}
+	return unused
+}
+
+// replay answers req with the first unused interaction matching it
+func (t *Transport) replay(req *http.Request, recorded *RecordedRequest) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// identical pseudonyms first, for replays with the key of the recording
+	for _, exact := range []bool{true, false} {
+		for i, interaction := range t.fixture.Interactions {
+			if t.replayed[i] {
+				continue
+			}
+			bindings, ok := t.matches(interaction.Request, *recorded, exact)
+			if !ok {
+				continue
+			}
+			for recordedValue, incomingValue := range bindings {
+				t.bindings[recordedValue] = incomingValue
+				t.bound[incomingValue] = recordedValue
+			}
+			t.replayed[i] = true
+			header := interaction.Response.Header.Clone()
+			if header == nil {
+				header = http.Header{}
+			}
+			return &http.Response{
+				StatusCode: interaction.Response.StatusCode,
+				Status:     fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(interaction.Response.Body)),
+				Request:    req,
+			}, nil
+		}
+	}
+	return nil, &UnrecordedRequestError{Request: *recorded, FixturePath: t.options.FixturePath}
+}
+
+// recordRequest returns the scrubbed form of req, leaving req readable
+func (t *Transport) recordRequest(req *http.Request) (*RecordedRequest, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return &RecordedRequest{
+		Method: req.Method,
+		URL:    normalizeURL(req.URL),
+		Header: t.scrubHeader(req.Header),
+		Body:   t.scrubBody(body),
+	}, nil
+}
+
+// scrubHeader returns a copy of header with the scrubbed headers redacted
+func (t *Transport) scrubHeader(header http.Header) http.Header {
+	scrubbed := http.Header{}
+	for k, v := range header {
+		if t.headers[http.CanonicalHeaderKey(k)] {
+			scrubbed[k] = []string{Redacted}
+			continue
+		}
+		scrubbed[k] = append([]string(nil), v...)
+	}
+	return scrubbed
+}
+
+// scrubBody returns body with the scrubbed JSON fields redacted and its
+// keys sorted. Bodies that aren't JSON are returned as is.
+func (t *Transport) scrubBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	normalized, err := json.Marshal(t.scrubValue(v, false))
+	if err != nil {
+		return string(body)
+	}
+	return string(normalized)
+}
+
+// scrubValue redacts the string leaves of v found under a scrubbed field
+func (t *Transport) scrubValue(v interface{}, scrub bool) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, child := range value {
+			value[k] = t.scrubValue(child, scrub || t.fields[strings.ToLower(k)])
+		}
+		return value
+	case []interface{}:
+		for i, child := range value {
+			value[i] = t.scrubValue(child, scrub)
+		}
+		return value
+	case string:
+		if scrub {
+			return Redact(t.options.Key, value)
+		}
+	}
+	return v
+}
+
+// matches tells whether a recorded request answers an incoming request, and
+// returns the new bindings of pseudonyms the match relies on. With exact,
+// the pseudonyms must also be identical.
+func (t *Transport) matches(recorded, incoming RecordedRequest, exact bool) (map[string]string, bool) {
+	if recorded.Method != incoming.Method || recorded.URL != incoming.URL {
+		return nil, false
+	}
+	if recorded.Body == incoming.Body && !strings.Contains(recorded.Body, redactedPrefix) {
+		return nil, true
+	}
+	var recordedBody, incomingBody interface{}
+	if json.Unmarshal([]byte(recorded.Body), &recordedBody) != nil || json.Unmarshal([]byte(incoming.Body), &incomingBody) != nil {
+		return nil, recorded.Body == incoming.Body
+	}
+	bindings := map[string]string{}
+	return bindings, t.matchValue(recordedBody, incomingBody, exact, bindings)
+}
+
+// matchValue tells whether the recorded JSON value matches the incoming one,
+// adding to bindings the pairs of pseudonyms bound by the match
+func (t *Transport) matchValue(recorded, incoming interface{}, exact bool, bindings map[string]string) bool {
+	switch r := recorded.(type) {
+	case map[string]interface{}:
+		i, ok := incoming.(map[string]interface{})
+		if !ok || len(r) != len(i) {
+			return false
+		}
+		for k, v := range r {
+			if iv, ok := i[k]; !ok || !t.matchValue(v, iv, exact, bindings) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		i, ok := incoming.([]interface{})
+		if !ok || len(r) != len(i) {
+			return false
+		}
+		for k := range r {
+			if !t.matchValue(r[k], i[k], exact, bindings) {
+				return false
+			}
+		}
+		return true
+	case string:
+		i, ok := incoming.(string)
+		if !ok {
+			return false
+		}
+		if !strings.HasPrefix(r, redactedPrefix) || !strings.HasPrefix(i, redactedPrefix) {
+			return r == i
+		}
+		if exact && r != i {
+			return false
+		}
+		return t.bind(r, i, bindings)
+	default:
+		return recorded == incoming
+	}
+}
+
+// bind tells whether the recorded pseudonym can stand for the incoming one,
+// given the bindings of the previous matches and of the current one
+func (t *Transport) bind(recorded, incoming string, bindings map[string]string) bool {
+	if bound, ok := t.bindings[recorded]; ok {
+		return bound == incoming
+	}
+	if bound, ok := bindings[recorded]; ok {
+		return bound == incoming
+	}
+	if _, ok := t.bound[incoming]; ok {
+		return false
+	}
+	for _, other := range bindings {
+		if other == incoming {
+			return false
+		}
+	}
+	bindings[recorded] = incoming
+	return true
+}
+
+// normalizeURL returns u with its query parameters sorted
+func normalizeURL(u *url.URL) string {
+	normalized := *u
+	normalized.RawQuery = u.Query().Encode()
+	return normalized.String()
+}
+
+// UnrecordedRequestError is returned in ModeReplay for requests no unused
+// interaction of the fixture matches. It is never retried by azcore.
+type UnrecordedRequestError struct {
+	Request     RecordedRequest
+	FixturePath string
+}
+
+func (e *UnrecordedRequestError) Error() string {
+	return fmt.Sprintf("recording: no unused interaction in %s matches %s %s with body %s", e.FixturePath, e.Request.Method, e.Request.URL, e.Request.Body)
+}
+
+// NonRetriable tells azcore's retry policy not to retry the request
+func (e *UnrecordedRequestError) NonRetriable() {}
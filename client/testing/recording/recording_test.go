@@ -0,0 +1,216 @@
+package recording
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newRequest(t *testing.T, url, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	return req
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"value":[{"actionId":"read","accessDecision":"Allowed","roleAssignment":{"principalId":"oid-1"}}]}`)
+	}))
+	defer server.Close()
+
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	url := server.URL + "/checkAccess?b=2&a=1"
+	body := `{"Subject":{"Attributes":{"ObjectId":"oid-1","Groups":["g1","g2"]}},"Actions":[{"Id":"read"}]}`
+
+	recorder, err := NewTransport(Options{Mode: ModeRecord, FixturePath: fixture})
+	if err != nil {
+		t.Fatalf("Unable to create recorder: %v", err)
+	}
+	res, err := recorder.Do(newRequest(t, url, body))
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if got, _ := io.ReadAll(res.Body); !strings.Contains(string(got), "oid-1") {
+		t.Errorf("expected the live response to be returned unscrubbed, got %s", got)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Unable to save fixture: %v", err)
+	}
+
+	content, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "oid-1", "g1", "session=secret"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("expected %q to be scrubbed from the fixture", secret)
+		}
+	}
+
+	replayer, err := NewTransport(Options{Mode: ModeReplay, FixturePath: fixture})
+	if err != nil {
+		t.Fatalf("Unable to create replayer: %v", err)
+	}
+	// same request with reordered query and keys
+	res, err = replayer.Do(newRequest(t, server.URL+"/checkAccess?a=1&b=2",
+		`{"Actions":[{"Id":"read"}],"Subject":{"Attributes":{"Groups":["g1","g2"],"ObjectId":"oid-1"}}}`))
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 but got %d", res.StatusCode)
+	}
+	if got, _ := io.ReadAll(res.Body); !strings.Contains(string(got), `"accessDecision":"Allowed"`) {
+		t.Errorf("unexpected replayed body %s", got)
+	}
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("expected every interaction to be replayed but got %v", unused)
+	}
+
+	// interactions are replayed once, and other bodies or subjects never match
+	for _, body := range []string{
+		body,
+		`{"Actions":[{"Id":"write"}]}`,
+		`{"Subject":{"Attributes":{"ObjectId":"oid-2","Groups":["g1","g2"]}},"Actions":[{"Id":"read"}]}`,
+	} {
+		_, err = replayer.Do(newRequest(t, url, body))
+		var unrecorded *UnrecordedRequestError
+		if !errors.As(err, &unrecorded) {
+			t.Errorf("expected an UnrecordedRequestError but got '%v'", err)
+		}
+	}
+}
+
+func TestReplayTellsSubjectsApart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision := "NotAllowed"
+		if body, _ := io.ReadAll(r.Body); strings.Contains(string(body), "oid-1") {
+			decision = "Allowed"
+		}
+		_, _ = io.WriteString(w, `{"value":[{"actionId":"read","accessDecision":"`+decision+`"}]}`)
+	}))
+	defer server.Close()
+
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	bodyOf := func(objectId string) string {
+		return `{"Subject":{"Attributes":{"ObjectId":"` + objectId + `"}},"Actions":[{"Id":"read"}]}`
+	}
+
+	key := []byte("test-key")
+	recorder, err := NewTransport(Options{Mode: ModeRecord, FixturePath: fixture, Key: key})
+	if err != nil {
+		t.Fatalf("Unable to create recorder: %v", err)
+	}
+	for _, objectId := range []string{"oid-1", "oid-2"} {
+		if _, err := recorder.Do(newRequest(t, server.URL, bodyOf(objectId))); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Unable to save fixture: %v", err)
+	}
+
+	replayer, err := NewTransport(Options{Mode: ModeReplay, FixturePath: fixture, Key: key})
+	if err != nil {
+		t.Fatalf("Unable to create replayer: %v", err)
+	}
+	// replayed in the reverse order of the recording
+	for _, tt := range []struct {
+		objectId     string
+		wantDecision string
+	}{
+		{objectId: "oid-2", wantDecision: `"accessDecision":"NotAllowed"`},
+		{objectId: "oid-1", wantDecision: `"accessDecision":"Allowed"`},
+	} {
+		res, err := replayer.Do(newRequest(t, server.URL, bodyOf(tt.objectId)))
+		if err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+		if got, _ := io.ReadAll(res.Body); !strings.Contains(string(got), tt.wantDecision) {
+			t.Errorf("expected the response of %s to contain %s but got %s", tt.objectId, tt.wantDecision, got)
+		}
+	}
+}
+
+func TestReplayWithAnotherKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"value":[]}`)
+	}))
+	defer server.Close()
+
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	bodyOf := func(objectId, action string) string {
+		return `{"Subject":{"Attributes":{"ObjectId":"` + objectId + `"}},"Actions":[{"Id":"` + action + `"}]}`
+	}
+
+	recorder, err := NewTransport(Options{Mode: ModeRecord, FixturePath: fixture})
+	if err != nil {
+		t.Fatalf("Unable to create recorder: %v", err)
+	}
+	for _, body := range []string{bodyOf("oid-1", "read"), bodyOf("oid-2", "read"), bodyOf("oid-1", "write")} {
+		if _, err := recorder.Do(newRequest(t, server.URL, body)); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("Unable to save fixture: %v", err)
+	}
+	content, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "oid-") {
+		t.Errorf("expected the object ids to be scrubbed from the fixture")
+	}
+
+	// the replay key differs: the subjects are kept apart, not identified
+	replayer, err := NewTransport(Options{Mode: ModeReplay, FixturePath: fixture})
+	if err != nil {
+		t.Fatalf("Unable to create replayer: %v", err)
+	}
+	for _, tt := range []struct {
+		body    string
+		wantErr bool
+	}{
+		{body: bodyOf("oid-a", "read")},
+		{body: bodyOf("oid-b", "read")},
+		{body: bodyOf("oid-b", "write"), wantErr: true},
+		{body: bodyOf("oid-a", "write")},
+	} {
+		_, err := replayer.Do(newRequest(t, server.URL, tt.body))
+		if tt.wantErr != (err != nil) {
+			t.Errorf("expected error for %s to be %v but got '%v'", tt.body, tt.wantErr, err)
+		}
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		options Options
+	}{
+		{desc: "fail - invalid mode", options: Options{Mode: "Live", FixturePath: "fixture.json"}},
+		{desc: "fail - missing fixture path", options: Options{Mode: ModeRecord}},
+		{desc: "fail - missing fixture file", options: Options{Mode: ModeReplay, FixturePath: filepath.Join(t.TempDir(), "missing.json")}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if _, err := NewTransport(tt.options); err == nil {
+				t.Errorf("expected error to be 'non-nil' but got 'nil'")
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package tokens
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Kind is the kind of principal a token is minted for
+type Kind string
+
+// Kind possible values
+const (
+	User             Kind = "User"
+	Guest            Kind = "Guest"
+	ServicePrincipal Kind = "ServicePrincipal"
+	ManagedIdentity  Kind = "ManagedIdentity"
+)
+
+// Version is the Entra ID access token version
+type Version string
+
+// Version possible values
+const (
+	V1 Version = "1.0"
+	V2 Version = "2.0"
+)
+
+// Options describes the token to mint. Empty IDs are generated.
+type Options struct {
+	Kind    Kind
+	Version Version
+
+	TenantId      string
+	ObjectId      string
+	ApplicationId string
+	// HomeTenantId is the tenant a Guest comes from
+	HomeTenantId string
+	// Name is the display name, and the local part of the UPN, of users and guests
+	Name string
+
+	Audience string
+	Scopes   []string
+	Roles    []string
+
+	// Groups are the object IDs of the principal's groups
+	Groups []string
+	// GroupOverage replaces the groups claim with the _claim_names and
+	// _claim_sources claims Entra ID emits when a principal has too many
+	// groups to fit in the token
+	GroupOverage bool
+
+	// IssuedAt defaults to now, and Lifetime to an hour
+	IssuedAt time.Time
+	Lifetime time.Duration
+
+	// Claims are added to, or override, the generated claims
+	Claims map[string]interface{}
+}
+
+// Minter signs tokens shaped like Entra ID access tokens with an RSA key
+type Minter struct {
+	key   *rsa.PrivateKey
+	keyId string
+}
+
+// NewMinter returns a Minter with a generated 2048 bits key
+func NewMinter() (*Minter, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return NewMinterWithKey(key, newUUID())
+}
+
+// NewMinterWithKey returns a Minter signing with key, published as keyId
+func NewMinterWithKey(key *rsa.PrivateKey, keyId string) (*Minter, error) {
+	if key == nil {
+		return nil, fmt.Errorf("need an RSA key in creating token minter")
+	}
+	if keyId == "" {
+		return nil, fmt.Errorf("need a key ID in creating token minter")
+	}
+	return &Minter{key: key, keyId: keyId}, nil
+}
+
+// Mint returns a signed token described by options
+func (m *Minter) Mint(options Options) (string, error) {
+	claims, err := Claims(options)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.keyId
+	return token.SignedString(m.key)
+}
+
+// Claims returns the claims Mint signs for options
+func Claims(options Options) (jwt.MapClaims, error) {
+	if options.Kind == "" {
+		options.Kind = User
+	}
+	if options.Version == "" {
+		options.Version = V2
+	}
+	if options.Version != V1 && options.Version != V2 {
+		return nil, fmt.Errorf("version: %s is not valid, need a valid version in minting token", options.Version)
+	}
+	if options.TenantId == "" {
+		options.TenantId = newUUID()
+	}
+	if options.ObjectId == "" {
+		options.ObjectId = newUUID()
+	}
+	if options.ApplicationId == "" {
+		options.ApplicationId = newUUID()
+	}
+	if options.Name == "" {
+		options.Name = "user"
+	}
+	if options.Audience == "" {
+		options.Audience = "https://management.azure.com/"
+	}
+	if options.IssuedAt.IsZero() {
+		options.IssuedAt = time.Now()
+	}
+	if options.Lifetime == 0 {
+		options.Lifetime = time.Hour
+	}
+
+	v1 := options.Version == V1
+	claims := jwt.MapClaims{
+		"aud": options.Audience,
+		"iss": Issuer(options.TenantId, options.Version),
+		"iat": options.IssuedAt.Unix(),
+		"nbf": options.IssuedAt.Unix(),
+		"exp": options.IssuedAt.Add(options.Lifetime).Unix(),
+		"oid": options.ObjectId,
+		"sub": newUUID(),
+		"tid": options.TenantId,
+		"ver": string(options.Version),
+		"uti": newUUID(),
+	}
+	if v1 {
+		claims["appid"] = options.ApplicationId
+		claims["appidacr"] = "1"
+	} else {
+		claims["azp"] = options.ApplicationId
+		claims["azpacr"] = "1"
+	}
+
+	switch options.Kind {
+	case User, Guest:
+		claims["name"] = options.Name
+		claims["scp"] = "user_impersonation"
+		if len(options.Scopes) > 0 {
+			claims["scp"] = strings.Join(options.Scopes, " ")
+		}
+		claims["amr"] = []string{"pwd", "mfa"}
+		if v1 {
+			claims["appidacr"] = "0"
+		} else {
+			claims["azpacr"] = "0"
+		}
+		if options.Kind == User {
+			upn := options.Name + "@contoso.com"
+			if v1 {
+				claims["upn"] = upn
+				claims["unique_name"] = upn
+			} else {
+				claims["preferred_username"] = upn
+			}
+			break
+		}
+		if options.HomeTenantId == "" {
+			options.HomeTenantId = newUUID()
+		}
+		guest := options.Name + "@fabrikam.com"
+		claims["idp"] = Issuer(options.HomeTenantId, V1)
+		claims["altsecid"] = "5::" + newPUID()
+		if v1 {
+			claims["unique_name"] = "live.com#" + guest
+			claims["email"] = guest
+		} else {
+			claims["preferred_username"] = guest
+			claims["acct"] = 1
+		}
+	case ServicePrincipal, ManagedIdentity:
+		claims["sub"] = options.ObjectId
+		claims["idtyp"] = "app"
+		claims["idp"] = Issuer(options.TenantId, V1)
+		if options.Kind == ManagedIdentity {
+			claims["xms_mirid"] = fmt.Sprintf("/subscriptions/%s/resourcegroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s", newUUID(), options.Name)
+		}
+	default:
+		return nil, fmt.Errorf("kind: %s is not valid, need a valid kind in minting token", options.Kind)
+	}
+	if len(options.Roles) > 0 {
+		claims["roles"] = options.Roles
+	}
+
+	if options.GroupOverage {
+		graph := fmt.Sprintf("https://graph.microsoft.com/v1.0/users/%s/getMemberObjects", options.ObjectId)
+		if v1 {
+			graph = fmt.Sprintf("https://graph.windows.net/%s/users/%s/getMemberObjects", options.TenantId, options.ObjectId)
+		}
+		claims["_claim_names"] = map[string]interface{}{"groups": "src1"}
+		claims["_claim_sources"] = map[string]interface{}{"src1": map[string]interface{}{"endpoint": graph}}
+	} else if len(options.Groups) > 0 {
+		claims["groups"] = options.Groups
+	}
+
+	for k, v := range options.Claims {
+		claims[k] = v
+	}
+	return claims, nil
+}
+
+// Issuer returns the issuer of the tokens of tenantId
+func Issuer(tenantId string, version Version) string {
+	if version == V1 {
+		return fmt.Sprintf("https://sts.windows.net/%s/", tenantId)
+	}
+	return fmt.Sprintf("https://login.microsoftonline.com/%s/v2.0", tenantId)
+}
+
+// PublicKey returns the public key verifying the minted tokens
+func (m *Minter) PublicKey() *rsa.PublicKey {
+	return &m.key.PublicKey
+}
+
+// Keyfunc resolves the verification key of a minted token, for jwt.Parse
+func (m *Minter) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodRS256 {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	if kid, _ := token.Header["kid"].(string); kid != m.keyId {
+		return nil, fmt.Errorf("unknown key ID %v", token.Header["kid"])
+	}
+	return m.PublicKey(), nil
+}
+
+// JWK is a JSON Web Key of an RSA public key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyId     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the key set verifying the minted tokens
+func (m *Minter) JWKS() JWKS {
+	pub := m.PublicKey()
+	return JWKS{Keys: []JWK{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		KeyId:     m.keyId,
+		Algorithm: "RS256",
+		Modulus:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}}
+}
+
+// JWKSHandler serves the key set, e.g. from an httptest.Server standing in
+// for the discovery keys endpoint
+func (m *Minter) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(m.JWKS())
+	})
+}
+
+// PublicKey returns the RSA key of k
+func (k JWK) PublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newPUID returns a random PUID, 16 upper case hex digits
+func newPUID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%X", b)
+}
@@ -0,0 +1,161 @@
+package tokens
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/internal/test"
+)
+
+func TestMint(t *testing.T) {
+	minter, err := NewMinter()
+	if err != nil {
+		t.Fatalf("Unable to create minter: %v", err)
+	}
+	server := httptest.NewServer(minter.JWKSHandler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var jwks JWKS
+	if err := json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		t.Fatalf("Unable to decode JWKS: %v", err)
+	}
+	pub, err := jwks.Keys[0].PublicKey()
+	if err != nil {
+		t.Fatalf("Unable to decode JWK: %v", err)
+	}
+
+	pdp, err := client.NewRemotePDPClient("https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess", "https://authorization.azure.net/.default", test.FakeCredential{}, nil)
+	if err != nil {
+		t.Fatalf("Unable to create a new PDP client: %v", err)
+	}
+
+	oid, tid, appid := "00000000-0000-0000-0000-00000000000a", "00000000-0000-0000-0000-00000000000b", "00000000-0000-0000-0000-00000000000d"
+	groups := []string{"00000000-0000-0000-0000-0000000000c1"}
+	for _, tt := range []struct {
+		name        string
+		options     Options
+		wantClaims  []string
+		wantSubject client.SubjectAttributes
+	}{
+		{
+			name:        "user v1",
+			options:     Options{Kind: User, Version: V1, Groups: groups},
+			wantClaims:  []string{"upn", "unique_name", "appid", "scp"},
+			wantSubject: client.SubjectAttributes{ObjectId: oid, TenantId: tid, ApplicationId: appid, Groups: groups},
+		},
+		{
+			name:        "user v2",
+			options:     Options{Kind: User, Version: V2},
+			wantClaims:  []string{"preferred_username", "azp", "scp"},
+			wantSubject: client.SubjectAttributes{ObjectId: oid, TenantId: tid, ApplicationId: appid},
+		},
+		{
+			name:        "guest v1",
+			options:     Options{Kind: Guest, Version: V1},
+			wantClaims:  []string{"idp", "altsecid", "unique_name", "email"},
+			wantSubject: client.SubjectAttributes{ObjectId: oid, TenantId: tid, ApplicationId: appid},
+		},
+		{
+			name:        "guest v2",
+			options:     Options{Kind: Guest, Version: V2},
+			wantClaims:  []string{"idp", "altsecid", "acct"},
+			wantSubject: client.SubjectAttributes{ObjectId: oid, TenantId: tid, ApplicationId: appid},
+		},
+		{
+			name:        "service principal",
+			options:     Options{Kind: ServicePrincipal, Roles: []string{"Reader"}},
+			wantClaims:  []string{"idtyp", "roles"},
+			wantSubject: client.SubjectAttributes{ObjectId: oid, TenantId: tid, ApplicationId: appid},
+		},
+		{
+			name:        "managed identity",
+			options:     Options{Kind: ManagedIdentity, Version: V1},
+			wantClaims:  []string{"idtyp", "xms_mirid"},
+			wantSubject: client.SubjectAttributes{ObjectId: oid, TenantId: tid, ApplicationId: appid},
+		},
+		{
+			name:        "group overage",
+			options:     Options{Kind: User, GroupOverage: true, Groups: groups},
+			wantClaims:  []string{"_claim_names", "_claim_sources"},
+			wantSubject: client.SubjectAttributes{ObjectId: oid, TenantId: tid, ApplicationId: appid, ClaimName: client.GroupExpansion},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.ObjectId, tt.options.TenantId, tt.options.ApplicationId = oid, tid, appid
+			signed, err := minter.Mint(tt.options)
+			if err != nil {
+				t.Fatalf("Unable to mint token: %v", err)
+			}
+
+			claims := jwt.MapClaims{}
+			_, err = jwt.ParseWithClaims(signed, claims, func(token *jwt.Token) (interface{}, error) { return pub, nil })
+			if err != nil {
+				t.Fatalf("expected the JWKS to verify the token but got '%v'", err)
+			}
+			if _, err := jwt.Parse(signed, minter.Keyfunc); err != nil {
+				t.Errorf("expected Keyfunc to verify the token but got '%v'", err)
+			}
+			for _, claim := range tt.wantClaims {
+				if _, ok := claims[claim]; !ok {
+					t.Errorf("expected claim %s in %v", claim, claims)
+				}
+			}
+			if claims["ver"] != string(tt.options.Version) && tt.options.Version != "" {
+				t.Errorf("expected version %s but got %v", tt.options.Version, claims["ver"])
+			}
+
+			authzReq, err := pdp.CreateAuthorizationRequest("/subscriptions/sub", []string{"read"}, signed)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if diff := cmp.Diff(tt.wantSubject, authzReq.Subject.Attributes); diff != "" {
+				t.Errorf("incorrect subject attributes: %v", diff)
+			}
+		})
+	}
+}
+
+func TestMintExpiredAndInvalid(t *testing.T) {
+	minter, err := NewMinter()
+	if err != nil {
+		t.Fatalf("Unable to create minter: %v", err)
+	}
+	expired, err := minter.Mint(Options{IssuedAt: time.Now().Add(-2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Unable to mint token: %v", err)
+	}
+	if _, err := jwt.Parse(expired, minter.Keyfunc); err == nil {
+		t.Errorf("expected an expired token to fail validation")
+	}
+
+	other, err := NewMinter()
+	if err != nil {
+		t.Fatalf("Unable to create minter: %v", err)
+	}
+	signed, err := other.Mint(Options{})
+	if err != nil {
+		t.Fatalf("Unable to mint token: %v", err)
+	}
+	if _, err := jwt.Parse(signed, minter.Keyfunc); err == nil {
+		t.Errorf("expected a token of another key to fail validation")
+	}
+
+	if _, err := minter.Mint(Options{Kind: "Robot"}); err == nil {
+		t.Errorf("expected an invalid kind to fail")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/extauthz"
+)
+
+// shutdownTimeout is how long the servers wait for pending requests on exit
+const shutdownTimeout = 10 * time.Second
+
+// extAuthzFlags are the flags of the ext-authz command
+type extAuthzFlags struct {
+	endpoint     string
+	scope        string
+	credential   string
+	config       string
+	listen       string
+	healthListen string
+}
+
+// health serves /healthz, 200 while the process runs, and /readyz, 200 while
+// the service accepts check requests
+type health struct {
+	ready atomic.Bool
+}
+
+func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz":
+		fmt.Fprintln(w, "ok")
+	case "/readyz":
+		if !h.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func runExtAuthz(ctx context.Context, args []string, stdout io.Writer) error {
+	var f extAuthzFlags
+	fs := flag.NewFlagSet("ext-authz", flag.ContinueOnError)
+	fs.StringVar(&f.endpoint, "endpoint", "", "checkAccess URL of the regional PDP server (required)")
+	fs.StringVar(&f.scope, "scope", defaultScope, "oauth scope of the PDP server")
+	fs.StringVar(&f.credential, "credential", "default", "azidentity credential: default, cli, env, managed-identity or workload-identity")
+	fs.StringVar(&f.config, "config", "", "ext_authz config file mapping the routes to resources and actions, YAML or JSON (required)")
+	fs.StringVar(&f.listen, "listen", ":9191", "address of the ext_authz HTTP service")
+	fs.StringVar(&f.healthListen, "health-listen", ":9192", "address of the /healthz and /readyz endpoints")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.endpoint == "" {
+		return fmt.Errorf("need -endpoint")
+	}
+	if f.config == "" {
+		return fmt.Errorf("need -config")
+	}
+
+	service, err := newExtAuthzService(f)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", f.listen)
+	if err != nil {
+		return err
+	}
+	healthListener, err := net.Listen("tcp", f.healthListen)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	h := &health{}
+	server := &http.Server{Handler: service, ReadHeaderTimeout: 10 * time.Second}
+	healthServer := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 2)
+	go func() { errs <- server.Serve(listener) }()
+	go func() { errs <- healthServer.Serve(healthListener) }()
+	h.ready.Store(true)
+	fmt.Fprintf(stdout, "ext_authz listening on %s, health on %s\n", listener.Addr(), healthListener.Addr())
+
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+	h.ready.Store(false)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	shutdownErr := errors.Join(server.Shutdown(shutdownCtx), healthServer.Shutdown(shutdownCtx))
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return shutdownErr
+}
+
+// newExtAuthzService returns the ext_authz service of the flags
+func newExtAuthzService(f extAuthzFlags) (http.Handler, error) {
+	config, err := extauthz.LoadConfig(f.config)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := newCredential(f.credential)
+	if err != nil {
+		return nil, err
+	}
+	pdp, err := client.NewRemotePDPClient(f.endpoint, f.scope, cred, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+	return extauthz.NewService(extauthz.Options{Client: pdp, Config: config})
+}
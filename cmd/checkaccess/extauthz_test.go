@@ -0,0 +1,144 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/tokens"
+)
+
+func TestExtAuthz(t *testing.T) {
+	requests := respondWith(t, `{"value":[{"actionId":"Microsoft.Contoso/widgets/read","accessDecision":"Allowed"}]}`)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte(`routes:
+- method: GET
+  path: /widgets/{widgetName}
+  resource: /subscriptions/sub/providers/Microsoft.Contoso/widgets/{widgetName}
+  actions:
+  - id: Microsoft.Contoso/widgets/read
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	minter, err := tokens.NewMinter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := minter.Mint(tokens.Options{ObjectId: "oid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stdout, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := runExtAuthz(ctx, []string{"-endpoint", endpoint, "-config", config, "-listen", "127.0.0.1:0", "-health-listen", "127.0.0.1:0"}, w)
+		w.Close()
+		done <- err
+	}()
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 7 {
+		t.Fatalf("expected the listening addresses but got '%s'", line)
+	}
+	addr, healthAddr := strings.TrimSuffix(fields[3], ","), fields[6]
+
+	for _, tt := range []struct {
+		name          string
+		url           string
+		authorization string
+		wantStatus    int
+	}{
+		{
+			name:       "pass - healthz",
+			url:        "http://" + healthAddr + "/healthz",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "pass - readyz",
+			url:        "http://" + healthAddr + "/readyz",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "pass - check allowed",
+			url:           "http://" + addr + "/widgets/w1",
+			authorization: "Bearer " + token,
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:       "fail - check without token",
+			url:        "http://" + addr + "/widgets/w1",
+			wantStatus: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status to be '%d' but got '%d'", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+	if len(*requests) != 1 || (*requests)[0].Subject.Attributes.ObjectId != "oid" {
+		t.Errorf("expected 1 CheckAccess of oid but got '%+v'", *requests)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected error to be 'nil' but got '%v'", err)
+	}
+}
+
+func TestExtAuthzFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "fail - no endpoint",
+			args:    []string{"-config", "config.yaml"},
+			wantErr: "need -endpoint",
+		},
+		{
+			name:    "fail - no config",
+			args:    []string{"-endpoint", endpoint},
+			wantErr: "need -config",
+		},
+		{
+			name:    "fail - missing config file",
+			args:    []string{"-endpoint", endpoint, "-config", filepath.Join(t.TempDir(), "missing.yaml")},
+			wantErr: "error while reading the config file",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runExtAuthz(context.Background(), tt.args, io.Discard)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("expected error to start with '%s' but got '%v'", tt.wantErr, err)
+			}
+		})
+	}
+}
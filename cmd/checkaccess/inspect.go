@@ -0,0 +1,98 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+func runInspectToken(ctx context.Context, args []string, stdout io.Writer) error {
+	var token, tokenFile, output string
+	var trustedIssuers stringList
+	fs := flag.NewFlagSet("inspect-token", flag.ContinueOnError)
+	fs.StringVar(&token, "token", "", "access token to inspect")
+	fs.StringVar(&tokenFile, "token-file", "", "file holding the access token to inspect")
+	fs.Var(&trustedIssuers, "trusted-issuer", "accepted issuer (repeatable, comma separated); Entra ID issuers when empty")
+	fs.StringVar(&output, "output", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("output: %s is not valid, need table or json", output)
+	}
+	if tokenFile != "" {
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if token == "" {
+		return fmt.Errorf("need -token or -token-file")
+	}
+
+	inspection, err := client.InspectToken(token, &client.InspectOptions{TrustedIssuers: trustedIssuers})
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inspection)
+	}
+	return printInspection(stdout, inspection)
+}
+
+// printInspection writes inspection as human readable text
+func printInspection(w io.Writer, inspection *client.TokenInspection) error {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Object ID:\t%s\n", orDash(inspection.ObjectId))
+	fmt.Fprintf(tw, "Tenant ID:\t%s\n", orDash(inspection.TenantId))
+	fmt.Fprintf(tw, "Application ID:\t%s\n", orDash(inspection.ApplicationId))
+	fmt.Fprintf(tw, "Issuer:\t%s\n", orDash(inspection.Issuer))
+	fmt.Fprintf(tw, "Identity provider:\t%s\n", orDash(inspection.IdentityProvider))
+	fmt.Fprintf(tw, "Audience:\t%s\n", orDash(strings.Join(inspection.Audience, ", ")))
+	fmt.Fprintf(tw, "Version:\t%s\n", orDash(inspection.Version))
+	fmt.Fprintf(tw, "Issued at:\t%s\n", formatTime(inspection.IssuedAt))
+	fmt.Fprintf(tw, "Expires at:\t%s\n", formatTime(inspection.ExpiresAt))
+	fmt.Fprintf(tw, "Groups in token:\t%d\n", inspection.GroupCount)
+	fmt.Fprintf(tw, "Group overage claim:\t%t\n", inspection.HasClaimNames)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	subject, err := json.MarshalIndent(inspection.Subject, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nSubjectAttributes sent by CreateAuthorizationRequest:\n%s\n", subject)
+
+	if len(inspection.Anomalies) == 0 {
+		fmt.Fprintln(w, "\nNo anomalies found.")
+		return nil
+	}
+	fmt.Fprintln(w, "\nAnomalies:")
+	for _, a := range inspection.Anomalies {
+		fmt.Fprintf(w, "  [%s] %s\n", a.Code, a.Message)
+	}
+	return nil
+}
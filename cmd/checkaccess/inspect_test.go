@@ -0,0 +1,67 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/tokens"
+)
+
+func TestInspectToken(t *testing.T) {
+	minter, err := tokens.NewMinter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	overage, err := minter.Mint(tokens.Options{ObjectId: "oid", GroupOverage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredGuest, err := minter.Mint(tokens.Options{Kind: tokens.Guest, ObjectId: "oid", IssuedAt: time.Now().Add(-2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("table output", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if err := run(context.Background(), []string{"inspect-token", "-token", overage}, &stdout); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+		for _, want := range []string{"Object ID:", `"ObjectId": "oid"`, `"_claim_names": "{\"groups\":\"src1\"}"`, "[GroupOverage]"} {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("expected output to contain %q but got:\n%s", want, stdout.String())
+			}
+		}
+	})
+
+	t.Run("json output", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if err := run(context.Background(), []string{"inspect-token", "-token", expiredGuest, "-output", "json"}, &stdout); err != nil {
+			t.Fatalf("expected error to be 'nil' but got '%v'", err)
+		}
+		var inspection client.TokenInspection
+		if err := json.Unmarshal(stdout.Bytes(), &inspection); err != nil {
+			t.Fatalf("Unable to decode output: %v", err)
+		}
+		codes := map[client.TokenAnomalyCode]bool{}
+		for _, a := range inspection.Anomalies {
+			codes[a.Code] = true
+		}
+		if !codes[client.AnomalyGuest] || !codes[client.AnomalyExpired] {
+			t.Errorf("expected Guest and Expired anomalies but got %v", inspection.Anomalies)
+		}
+	})
+
+	t.Run("fail - missing token", func(t *testing.T) {
+		if err := run(context.Background(), []string{"inspect-token"}, &bytes.Buffer{}); err == nil {
+			t.Errorf("expected error to be 'non-nil' but got 'nil'")
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client/routepolicy"
+)
+
+func runLintPolicy(ctx context.Context, args []string, stdout io.Writer) error {
+	var policyFile, routesFile, operationsFile, output string
+	fs := flag.NewFlagSet("lint-policy", flag.ContinueOnError)
+	fs.StringVar(&policyFile, "policy", "", "route policy file, YAML or JSON")
+	fs.StringVar(&routesFile, "routes", "", "file of the routes the service serves, one \"METHOD /path\" per line")
+	fs.StringVar(&operationsFile, "operations", "", "operations of the provider: the JSON of its operations API or one action per line")
+	fs.StringVar(&output, "output", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if output != "text" && output != "json" {
+		return fmt.Errorf("output: %s is not valid, need text or json", output)
+	}
+	if policyFile == "" {
+		return fmt.Errorf("need -policy")
+	}
+
+	policy, err := routepolicy.Load(policyFile)
+	if err != nil {
+		return err
+	}
+
+	var options routepolicy.LintOptions
+	if routesFile != "" {
+		f, err := os.Open(routesFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if options.Routes, err = routepolicy.ParseRouteSpecs(f); err != nil {
+			return fmt.Errorf("routes: %w", err)
+		}
+	}
+	if operationsFile != "" {
+		data, err := os.ReadFile(operationsFile)
+		if err != nil {
+			return err
+		}
+		if options.KnownActions, err = routepolicy.ParseOperations(data); err != nil {
+			return fmt.Errorf("operations: %w", err)
+		}
+	}
+
+	issues := routepolicy.Lint(policy, options)
+	if output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if issues == nil {
+			issues = []routepolicy.LintIssue{}
+		}
+		if err := enc.Encode(issues); err != nil {
+			return err
+		}
+	} else if len(issues) == 0 {
+		fmt.Fprintf(stdout, "%s: %d routes, no issues found\n", policyFile, len(policy.Routes))
+	} else {
+		for _, issue := range issues {
+			fmt.Fprintf(stdout, "[%s] %s\n", issue.Kind, issue.Message)
+		}
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issues found in %s", len(issues), policyFile)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintPolicy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	policy := write("policy.yaml", `routes:
+- method: GET
+  path: /subscriptions/{subscriptionId}/providers/Microsoft.Contoso/widgets/{widgetName}
+  resource: /subscriptions/{subscriptionId}/providers/Microsoft.Contoso/widgets/{widgetName}
+  actions:
+  - id: Microsoft.Contoso/widgets/read
+`)
+	routes := write("routes.txt", "GET /subscriptions/{s}/providers/Microsoft.Contoso/widgets/{w}\n")
+	unmapped := write("unmapped.txt", "DELETE /subscriptions/{s}/providers/Microsoft.Contoso/widgets/{w}\n")
+	operations := write("operations.txt", "Microsoft.Contoso/widgets/write\n")
+
+	for _, tt := range []struct {
+		name       string
+		args       []string
+		wantErr    string
+		wantOutput []string
+	}{
+		{
+			name:       "pass - no issues",
+			args:       []string{"-policy", policy, "-routes", routes},
+			wantOutput: []string{"1 routes, no issues found"},
+		},
+		{
+			name:       "fail - unmapped route and unknown action",
+			args:       []string{"-policy", policy, "-routes", unmapped, "-operations", operations},
+			wantErr:    "2 issues found in " + policy,
+			wantOutput: []string{"[UnmappedRoute] no policy route maps DELETE", "[UnknownAction] action Microsoft.Contoso/widgets/read"},
+		},
+		{
+			name:       "fail - json output",
+			args:       []string{"-policy", policy, "-operations", operations, "-output", "json"},
+			wantErr:    "1 issues found in " + policy,
+			wantOutput: []string{`"kind": "UnknownAction"`},
+		},
+		{
+			name:    "fail - no policy",
+			wantErr: "need -policy",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := run(context.Background(), append([]string{"lint-policy"}, tt.args...), &stdout)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("expected error to be '%s' but got '%v'", tt.wantErr, err)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("expected output to contain %q but got:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
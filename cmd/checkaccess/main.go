@@ -0,0 +1,133 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+// checkaccess sends ad-hoc authorization queries to a PDP server.
+//
+// Usage:
+//
+//	checkaccess <command> [flags]
+//
+// Run "checkaccess <command> -h" for the flags of a command.
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// defaultScope is the oauth scope of the public cloud PDP servers
+const defaultScope = "https://authorization.azure.net/.default"
+
+// command is a subcommand of checkaccess
+type command struct {
+	description string
+	run         func(ctx context.Context, args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"query":         {"send an AuthorizationRequest and print the decisions", runQuery},
+	"inspect-token": {"show the SubjectAttributes derived from a token and its anomalies", runInspectToken},
+	"review":        {"check a matrix of subjects, resources and actions and report who can do what", runReview},
+	"lint-policy":   {"validate a route policy file and report unmapped routes and unknown actions", runLintPolicy},
+	"ext-authz":     {"serve the Envoy ext_authz HTTP protocol, checking requests against a route config", runExtAuthz},
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage())
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage())
+	}
+	return cmd.run(ctx, args[1:], stdout)
+}
+
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("usage: checkaccess <command> [flags]\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-14s %s\n", name, commands[name].description)
+	}
+	return b.String()
+}
+
+// newCredential returns the azidentity credential of kind. It is a variable
+// so tests can replace it.
+var newCredential = func(kind string) (azcore.TokenCredential, error) {
+	switch kind {
+	case "default":
+		return azidentity.NewDefaultAzureCredential(nil)
+	case "cli":
+		return azidentity.NewAzureCLICredential(nil)
+	case "env":
+		return azidentity.NewEnvironmentCredential(nil)
+	case "managed-identity":
+		return azidentity.NewManagedIdentityCredential(nil)
+	case "workload-identity":
+		return azidentity.NewWorkloadIdentityCredential(nil)
+	default:
+		return nil, fmt.Errorf("credential: %s is not valid, need one of default, cli, env, managed-identity, workload-identity", kind)
+	}
+}
+
+// clientOptions are the azcore options of the PDP clients the commands
+// create. It is a variable so tests can replace the transport.
+var clientOptions *azcore.ClientOptions
+
+// stringList is a flag that can be repeated or given comma separated values
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s = append(*s, v)
+		}
+	}
+	return nil
+}
+
+// keyValues is a repeatable key=value flag
+type keyValues map[string]interface{}
+
+func (kv keyValues) String() string {
+	pairs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("attribute: %s is not valid, need key=value", value)
+	}
+	kv[strings.TrimSpace(k)] = v
+	return nil
+}
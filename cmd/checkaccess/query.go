@@ -0,0 +1,184 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// queryFlags are the flags of the query command
+type queryFlags struct {
+	endpoint    string
+	scope       string
+	credential  string
+	output      string
+	requestFile string
+
+	token       string
+	tokenFile   string
+	objectId    string
+	tenantId    string
+	groups      stringList
+	resource    string
+	actions     stringList
+	dataActions stringList
+
+	resourceAttributes    keyValues
+	environmentAttributes keyValues
+}
+
+func runQuery(ctx context.Context, args []string, stdout io.Writer) error {
+	f := queryFlags{resourceAttributes: keyValues{}, environmentAttributes: keyValues{}}
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.StringVar(&f.endpoint, "endpoint", "", "checkAccess URL of the regional PDP server (required)")
+	fs.StringVar(&f.scope, "scope", defaultScope, "oauth scope of the PDP server")
+	fs.StringVar(&f.credential, "credential", "default", "azidentity credential: default, cli, env, managed-identity or workload-identity")
+	fs.StringVar(&f.output, "output", "table", "output format: table or json")
+	fs.StringVar(&f.requestFile, "request", "", "JSON file holding the AuthorizationRequest; the subject, resource and action flags are ignored")
+	fs.StringVar(&f.token, "token", "", "access token of the subject")
+	fs.StringVar(&f.tokenFile, "token-file", "", "file holding the access token of the subject")
+	fs.StringVar(&f.objectId, "object-id", "", "object ID of the subject, when no token is given")
+	fs.StringVar(&f.tenantId, "tenant-id", "", "tenant ID of the subject, when no token is given")
+	fs.Var(&f.groups, "groups", "group object IDs of the subject, when no token is given (repeatable, comma separated)")
+	fs.StringVar(&f.resource, "resource", "", "ID of the resource")
+	fs.Var(&f.actions, "action", "action to check (repeatable, comma separated)")
+	fs.Var(&f.dataActions, "data-action", "data action to check (repeatable, comma separated)")
+	fs.Var(f.resourceAttributes, "resource-attribute", "resource attribute key=value (repeatable)")
+	fs.Var(f.environmentAttributes, "environment-attribute", "environment attribute key=value (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.endpoint == "" {
+		return fmt.Errorf("need -endpoint")
+	}
+	if f.output != "table" && f.output != "json" {
+		return fmt.Errorf("output: %s is not valid, need table or json", f.output)
+	}
+
+	cred, err := newCredential(f.credential)
+	if err != nil {
+		return err
+	}
+	pdp, err := client.NewRemotePDPClient(f.endpoint, f.scope, cred, clientOptions)
+	if err != nil {
+		return err
+	}
+
+	authzReq, err := buildRequest(pdp, f)
+	if err != nil {
+		return err
+	}
+	res, err := pdp.CheckAccess(ctx, *authzReq)
+	if err != nil {
+		return err
+	}
+
+	if f.output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	return printDecisions(stdout, res)
+}
+
+// buildRequest returns the AuthorizationRequest described by f
+func buildRequest(pdp client.RemotePDPClient, f queryFlags) (*client.AuthorizationRequest, error) {
+	if f.requestFile != "" {
+		content, err := os.ReadFile(f.requestFile)
+		if err != nil {
+			return nil, err
+		}
+		var authzReq client.AuthorizationRequest
+		if err := json.Unmarshal(content, &authzReq); err != nil {
+			return nil, fmt.Errorf("error while parsing %s, err: %w", f.requestFile, err)
+		}
+		return &authzReq, nil
+	}
+
+	if f.resource == "" {
+		return nil, fmt.Errorf("need -resource or -request")
+	}
+	if len(f.actions)+len(f.dataActions) == 0 {
+		return nil, fmt.Errorf("need -action, -data-action or -request")
+	}
+
+	token := f.token
+	if f.tokenFile != "" {
+		content, err := os.ReadFile(f.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	var authzReq *client.AuthorizationRequest
+	switch {
+	case token != "":
+		var err error
+		authzReq, err = pdp.CreateAuthorizationRequest(f.resource, nil, token)
+		if err != nil {
+			return nil, err
+		}
+	case f.objectId != "":
+		authzReq = &client.AuthorizationRequest{
+			Subject: client.SubjectInfo{Attributes: client.SubjectAttributes{
+				ObjectId: f.objectId,
+				TenantId: f.tenantId,
+				Groups:   f.groups,
+			}},
+			Resource: client.ResourceInfo{Id: f.resource},
+		}
+	default:
+		return nil, fmt.Errorf("need -token, -token-file or -object-id")
+	}
+
+	authzReq.Actions = []client.ActionInfo{}
+	for _, action := range f.actions {
+		authzReq.Actions = append(authzReq.Actions, client.ActionInfo{Id: action})
+	}
+	for _, action := range f.dataActions {
+		authzReq.Actions = append(authzReq.Actions, client.ActionInfo{Id: action, IsDataAction: true})
+	}
+	if len(f.resourceAttributes) > 0 {
+		authzReq.Resource.Attributes = client.Attributes(f.resourceAttributes)
+	}
+	if len(f.environmentAttributes) > 0 {
+		authzReq.Environment.Attributes = client.Attributes(f.environmentAttributes)
+	}
+	return authzReq, nil
+}
+
+// printDecisions writes the decisions of res as a table
+func printDecisions(w io.Writer, res *client.AuthorizationDecisionResponse) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tDECISION\tDATA ACTION\tROLE ASSIGNMENT\tROLE DEFINITION\tSCOPE\tCONDITION\tDENY ASSIGNMENT")
+	for _, d := range res.Value {
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\t%s\t%s\t%s\n",
+			d.ActionId,
+			d.AccessDecision,
+			d.IsDataAction,
+			orDash(d.RoleAssignment.Id),
+			orDash(d.RoleAssignment.RoleDefinitionId),
+			orDash(d.RoleAssignment.Scope),
+			orDash(d.RoleAssignment.Condition),
+			orDash(d.DenyAssignment.Id))
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/fault"
+	"github.com/Azure/checkaccess-v2-go-sdk/client/testing/tokens"
+)
+
+const endpoint = "https://westus.authorization.azure.net/providers/Microsoft.Authorization/checkAccess?api-version=2021-06-01-preview"
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fake-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// respondWith makes the commands' PDP clients answer every call with body.
+// The requests they send are captured in requests.
+func respondWith(t *testing.T, body string) *[]client.AuthorizationRequest {
+	t.Helper()
+	var requests []client.AuthorizationRequest
+	capture := fault.Fault(func(req *http.Request, next policy.Transporter) (*http.Response, error) {
+		content, _ := io.ReadAll(req.Body)
+		var authzReq client.AuthorizationRequest
+		if err := json.Unmarshal(content, &authzReq); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		requests = append(requests, authzReq)
+		return fault.Respond(http.StatusOK, body)(req, next)
+	})
+	schedule := fault.Random(0, fault.Choice{Probability: 1, Fault: capture})
+
+	prevCredential, prevOptions := newCredential, clientOptions
+	newCredential = func(string) (azcore.TokenCredential, error) { return fakeCredential{}, nil }
+	clientOptions = &azcore.ClientOptions{Transport: fault.NewTransport(schedule, nil)}
+	t.Cleanup(func() { newCredential, clientOptions = prevCredential, prevOptions })
+	return &requests
+}
+
+func TestQuery(t *testing.T) {
+	body := `{"value":[
+		{"actionId":"Microsoft.Compute/virtualMachines/read","accessDecision":"Allowed","roleAssignment":{"id":"ra1","roleDefinitionId":"rd1","scope":"/subscriptions/sub"}},
+		{"actionId":"Microsoft.Compute/virtualMachines/delete","accessDecision":"Denied","denyAssignment":{"id":"da1"}}]}`
+	resource := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm"
+
+	minter, err := tokens.NewMinter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := minter.Mint(tokens.Options{ObjectId: "token-oid", TenantId: "tid", ApplicationId: "appid", Groups: []string{"g1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestFile := filepath.Join(t.TempDir(), "request.json")
+	if err := os.WriteFile(requestFile, []byte(`{"Subject":{"Attributes":{"ObjectId":"file-oid"}},"Actions":[{"Id":"read"}],"Resource":{"Id":"/subscriptions/sub"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc        string
+		args        []string
+		wantRequest client.AuthorizationRequest
+		wantOutput  []string
+		wantErr     string
+	}{
+		{
+			desc: "explicit subject, table output",
+			args: []string{"-object-id", "oid", "-groups", "g1,g2", "-resource", resource,
+				"-action", "Microsoft.Compute/virtualMachines/read", "-data-action", "Microsoft.Storage/blobs/read",
+				"-resource-attribute", "tag=prod"},
+			wantRequest: client.AuthorizationRequest{
+				Subject: client.SubjectInfo{Attributes: client.SubjectAttributes{ObjectId: "oid", Groups: []string{"g1", "g2"}}},
+				Actions: []client.ActionInfo{
+					{Id: "Microsoft.Compute/virtualMachines/read"},
+					{Id: "Microsoft.Storage/blobs/read", IsDataAction: true},
+				},
+				Resource: client.ResourceInfo{Id: resource, Attributes: client.Attributes{"tag": "prod"}},
+			},
+			wantOutput: []string{"ACTION", "Allowed", "ra1", "rd1", "/subscriptions/sub", "Denied", "da1"},
+		},
+		{
+			desc: "subject from token, json output",
+			args: []string{"-token", token, "-resource", resource, "-action", "read", "-output", "json"},
+			wantRequest: client.AuthorizationRequest{
+				Subject:  client.SubjectInfo{Attributes: client.SubjectAttributes{ObjectId: "token-oid", TenantId: "tid", ApplicationId: "appid", Groups: []string{"g1"}}},
+				Actions:  []client.ActionInfo{{Id: "read"}},
+				Resource: client.ResourceInfo{Id: resource},
+			},
+			wantOutput: []string{`"accessDecision": "Allowed"`, `"id": "da1"`},
+		},
+		{
+			desc: "request from file",
+			args: []string{"-request", requestFile},
+			wantRequest: client.AuthorizationRequest{
+				Subject:  client.SubjectInfo{Attributes: client.SubjectAttributes{ObjectId: "file-oid"}},
+				Actions:  []client.ActionInfo{{Id: "read"}},
+				Resource: client.ResourceInfo{Id: "/subscriptions/sub"},
+			},
+			wantOutput: []string{"Allowed"},
+		},
+		{
+			desc:    "fail - missing subject",
+			args:    []string{"-resource", resource, "-action", "read"},
+			wantErr: "need -token, -token-file or -object-id",
+		},
+		{
+			desc:    "fail - missing action",
+			args:    []string{"-object-id", "oid", "-resource", resource},
+			wantErr: "need -action, -data-action or -request",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			requests := respondWith(t, body)
+			var stdout bytes.Buffer
+
+			err := run(context.Background(), append([]string{"query", "-endpoint", endpoint}, tt.args...), &stdout)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("expected error to be '%s' but got '%v'", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("expected 1 request but got %d", len(*requests))
+			}
+			if diff := cmp.Diff(tt.wantRequest, (*requests)[0]); diff != "" {
+				t.Errorf("incorrect request: %v", diff)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("expected output to contain %q but got:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if err := run(context.Background(), []string{"unknown"}, io.Discard); err == nil {
+		t.Errorf("expected error to be 'non-nil' for an unknown command")
+	}
+}
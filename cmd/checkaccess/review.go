@@ -0,0 +1,445 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// reviewRow is a (subject, resource, action) triple of an access review
+type reviewRow struct {
+	Subject      string `json:"subject"`
+	Resource     string `json:"resource"`
+	Action       string `json:"action"`
+	IsDataAction bool   `json:"isDataAction,omitempty"`
+}
+
+// reviewMatrix is the JSON input form expanding to subjects × resources × actions
+type reviewMatrix struct {
+	Subjects    []string `json:"subjects"`
+	Resources   []string `json:"resources"`
+	Actions     []string `json:"actions"`
+	DataActions []string `json:"dataActions"`
+}
+
+// reviewCall is a CheckAccess call of the review plan
+type reviewCall struct {
+	Subject  string              `json:"subject"`
+	Resource string              `json:"resource"`
+	Actions  []client.ActionInfo `json:"actions"`
+}
+
+// key identifies the call in the checkpoint file. Data actions are prefixed
+// so they are not confused with the control actions of the same ID.
+func (c reviewCall) key() string {
+	ids := make([]string, len(c.Actions))
+	for i, a := range c.Actions {
+		ids[i] = a.Id
+		if a.IsDataAction {
+			ids[i] = "data:" + a.Id
+		}
+	}
+	return c.Subject + "|" + c.Resource + "|" + strings.Join(ids, ",")
+}
+
+// reviewResult is the outcome of a reviewCall, as stored in the checkpoint
+type reviewResult struct {
+	Call      reviewCall                     `json:"call"`
+	Decisions []client.AuthorizationDecision `json:"decisions,omitempty"`
+	Error     string                         `json:"error,omitempty"`
+}
+
+// reviewOptions configures the execution of a review plan
+type reviewOptions struct {
+	concurrency int
+	// interval is the minimum time between two calls, no limit when zero
+	interval   time.Duration
+	checkpoint string
+	// groupExpansion asks the PDP to expand the group memberships of the
+	// subjects, so the role assignments of their groups are considered
+	groupExpansion bool
+}
+
+func runReview(ctx context.Context, args []string, stdout io.Writer) error {
+	var endpoint, scope, credential, input, format, out, checkpoint string
+	var concurrency, maxActions int
+	var rate float64
+	var groupExpansion bool
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	fs.StringVar(&endpoint, "endpoint", "", "checkAccess URL of the regional PDP server (required)")
+	fs.StringVar(&scope, "scope", defaultScope, "oauth scope of the PDP server")
+	fs.StringVar(&credential, "credential", "default", "azidentity credential: default, cli, env, managed-identity or workload-identity")
+	fs.StringVar(&input, "input", "", "CSV file of subject,resource,action[,dataAction] rows, or JSON file of rows or of a {subjects,resources,actions,dataActions} matrix (required)")
+	fs.StringVar(&format, "format", "csv", "report format: csv, json or markdown")
+	fs.StringVar(&out, "out", "", "report file, stdout when empty")
+	fs.StringVar(&checkpoint, "checkpoint", "", "file recording completed calls; a rerun skips them")
+	fs.IntVar(&concurrency, "concurrency", 4, "maximum number of calls in flight")
+	fs.IntVar(&maxActions, "max-actions", 50, "maximum number of actions per call")
+	fs.Float64Var(&rate, "rate", 10, "maximum number of calls per second, unlimited when 0")
+	fs.BoolVar(&groupExpansion, "group-expansion", true, "ask the PDP to expand the group memberships of the subjects, so access granted through groups is reported")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if endpoint == "" || input == "" {
+		return fmt.Errorf("need -endpoint and -input")
+	}
+	if format != "csv" && format != "json" && format != "markdown" {
+		return fmt.Errorf("format: %s is not valid, need csv, json or markdown", format)
+	}
+	if concurrency < 1 || maxActions < 1 || rate < 0 {
+		return fmt.Errorf("need -concurrency and -max-actions of at least 1 and a non-negative -rate")
+	}
+
+	rows, err := readReviewInput(input)
+	if err != nil {
+		return err
+	}
+	calls := planReview(rows, maxActions)
+
+	cred, err := newCredential(credential)
+	if err != nil {
+		return err
+	}
+	pdp, err := client.NewRemotePDPClient(endpoint, scope, cred, clientOptions)
+	if err != nil {
+		return err
+	}
+
+	options := reviewOptions{concurrency: concurrency, checkpoint: checkpoint, groupExpansion: groupExpansion}
+	if rate > 0 {
+		options.interval = time.Duration(float64(time.Second) / rate)
+	}
+	results, err := executeReview(ctx, pdp, calls, options)
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return writeReviewReport(w, format, results)
+}
+
+// readReviewInput reads the rows of a CSV or JSON review input
+func readReviewInput(path string) ([]reviewRow, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		trimmed := strings.TrimSpace(string(content))
+		if strings.HasPrefix(trimmed, "[") {
+			var rows []reviewRow
+			if err := json.Unmarshal(content, &rows); err != nil {
+				return nil, fmt.Errorf("error while parsing %s, err: %w", path, err)
+			}
+			return rows, nil
+		}
+		var matrix reviewMatrix
+		if err := json.Unmarshal(content, &matrix); err != nil {
+			return nil, fmt.Errorf("error while parsing %s, err: %w", path, err)
+		}
+		var rows []reviewRow
+		for _, subject := range matrix.Subjects {
+			for _, resource := range matrix.Resources {
+				for _, action := range matrix.Actions {
+					rows = append(rows, reviewRow{Subject: subject, Resource: resource, Action: action})
+				}
+				for _, action := range matrix.DataActions {
+					rows = append(rows, reviewRow{Subject: subject, Resource: resource, Action: action, IsDataAction: true})
+				}
+			}
+		}
+		return rows, nil
+	}
+
+	r := csv.NewReader(strings.NewReader(string(content)))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing %s, err: %w", path, err)
+	}
+	var rows []reviewRow
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && strings.EqualFold(record[0], "subject") {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("%s:%d: need subject,resource,action", path, i+1)
+		}
+		row := reviewRow{Subject: record[0], Resource: record[1], Action: record[2]}
+		if len(record) > 3 {
+			row.IsDataAction = strings.EqualFold(strings.TrimSpace(record[3]), "true")
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// planReview returns the fewest calls covering rows: one call per subject,
+// resource and kind of action, split when it has more than maxActions
+// distinct actions. Data and control actions are never mixed in a call, so
+// their decisions are not confused when they share an ID.
+func planReview(rows []reviewRow, maxActions int) []reviewCall {
+	type target struct {
+		subject, resource string
+		isDataAction      bool
+	}
+	actions := map[target][]client.ActionInfo{}
+	seen := map[target]map[string]bool{}
+	var order []target
+
+	for _, row := range rows {
+		t := target{row.Subject, row.Resource, row.IsDataAction}
+		if seen[t] == nil {
+			seen[t] = map[string]bool{}
+			order = append(order, t)
+		}
+		if seen[t][row.Action] {
+			continue
+		}
+		seen[t][row.Action] = true
+		actions[t] = append(actions[t], client.ActionInfo{Id: row.Action, IsDataAction: row.IsDataAction})
+	}
+
+	var calls []reviewCall
+	for _, t := range order {
+		all := actions[t]
+		for start := 0; start < len(all); start += maxActions {
+			end := min(start+maxActions, len(all))
+			calls = append(calls, reviewCall{Subject: t.subject, Resource: t.resource, Actions: all[start:end]})
+		}
+	}
+	return calls
+}
+
+// executeReview runs calls with bounded concurrency and rate, skipping and
+// appending to the checkpoint file. Failed calls are reported but not
+// checkpointed, so a rerun retries them.
+func executeReview(ctx context.Context, pdp client.RemotePDPClient, calls []reviewCall, options reviewOptions) ([]reviewResult, error) {
+	done := map[string]reviewResult{}
+	var checkpoint *os.File
+	if options.checkpoint != "" {
+		previous, err := readCheckpoint(options.checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range previous {
+			done[r.Call.key()] = r
+		}
+		checkpoint, err = os.OpenFile(options.checkpoint, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		defer checkpoint.Close()
+	}
+
+	var ticker *time.Ticker
+	if options.interval > 0 {
+		ticker = time.NewTicker(options.interval)
+		defer ticker.Stop()
+	}
+
+	results := make([]reviewResult, len(calls))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var checkpointErr error
+	sem := make(chan struct{}, options.concurrency)
+
+	for i, call := range calls {
+		if r, ok := done[call.key()]; ok {
+			results[i] = r
+			continue
+		}
+		if ticker != nil {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				wg.Wait()
+				return nil, ctx.Err()
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(i int, call reviewCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := reviewResult{Call: call}
+			subject := client.SubjectAttributes{ObjectId: call.Subject}
+			if options.groupExpansion {
+				subject.ClaimName = client.GroupExpansion
+			}
+			res, err := pdp.CheckAccess(ctx, client.AuthorizationRequest{
+				Subject:  client.SubjectInfo{Attributes: subject},
+				Actions:  call.Actions,
+				Resource: client.ResourceInfo{Id: call.Resource},
+			})
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Decisions = res.Value
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = result
+			if checkpoint != nil && err == nil {
+				line, err := json.Marshal(result)
+				if err == nil {
+					_, err = checkpoint.Write(append(line, '\n'))
+				}
+				if err != nil && checkpointErr == nil {
+					checkpointErr = fmt.Errorf("error while writing checkpoint, err: %w", err)
+				}
+			}
+		}(i, call)
+	}
+	wg.Wait()
+
+	return results, checkpointErr
+}
+
+// readCheckpoint returns the results recorded in the checkpoint file at path
+func readCheckpoint(path string) ([]reviewResult, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []reviewResult
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var r reviewResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// a line cut by an interruption is retried
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, scanner.Err()
+}
+
+// reviewReportRow is a line of the review report
+type reviewReportRow struct {
+	Subject          string `json:"subject"`
+	Resource         string `json:"resource"`
+	Action           string `json:"action"`
+	Decision         string `json:"decision"`
+	RoleAssignmentId string `json:"roleAssignmentId,omitempty"`
+	RoleDefinitionId string `json:"roleDefinitionId,omitempty"`
+	Scope            string `json:"scope,omitempty"`
+	DenyAssignmentId string `json:"denyAssignmentId,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// reviewReport flattens results to one row per subject, resource and action
+func reviewReport(results []reviewResult) []reviewReportRow {
+	var rows []reviewReportRow
+	for _, r := range results {
+		decisions := map[string]client.AuthorizationDecision{}
+		for _, d := range r.Decisions {
+			decisions[d.ActionId] = d
+		}
+		for _, action := range r.Call.Actions {
+			row := reviewReportRow{Subject: r.Call.Subject, Resource: r.Call.Resource, Action: action.Id}
+			d, ok := decisions[action.Id]
+			switch {
+			case r.Error != "":
+				row.Decision, row.Error = "Error", r.Error
+			case !ok:
+				row.Decision = "Missing"
+			default:
+				row.Decision = string(d.AccessDecision)
+				row.RoleAssignmentId = d.RoleAssignment.Id
+				row.RoleDefinitionId = d.RoleAssignment.RoleDefinitionId
+				row.Scope = d.RoleAssignment.Scope
+				row.DenyAssignmentId = d.DenyAssignment.Id
+			}
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Subject != rows[j].Subject {
+			return rows[i].Subject < rows[j].Subject
+		}
+		return rows[i].Resource < rows[j].Resource
+	})
+	return rows
+}
+
+// writeReviewReport writes the report of results in format
+func writeReviewReport(w io.Writer, format string, results []reviewResult) error {
+	rows := reviewReport(results)
+	header := []string{"subject", "resource", "action", "decision", "roleAssignmentId", "roleDefinitionId", "scope", "denyAssignmentId", "error"}
+	values := func(r reviewReportRow) []string {
+		return []string{r.Subject, r.Resource, r.Action, r.Decision, r.RoleAssignmentId, r.RoleDefinitionId, r.Scope, r.DenyAssignmentId, r.Error}
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "markdown":
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for _, r := range rows {
+			cells := values(r)
+			for i, c := range cells {
+				cells[i] = strings.ReplaceAll(c, "|", `\|`)
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		return nil
+	default:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := cw.Write(values(r)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/Azure/checkaccess-v2-go-sdk/client"
+)
+
+// reviewClient allows the read actions, fails for the subjects in failing
+// and counts its calls
+type reviewClient struct {
+	mu      sync.Mutex
+	calls   int
+	failing map[string]bool
+}
+
+func (c *reviewClient) CheckAccess(ctx context.Context, authzReq client.AuthorizationRequest) (*client.AuthorizationDecisionResponse, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	if c.failing[authzReq.Subject.Attributes.ObjectId] {
+		return nil, errors.New("unavailable")
+	}
+	res := &client.AuthorizationDecisionResponse{}
+	for _, a := range authzReq.Actions {
+		d := client.AuthorizationDecision{ActionId: a.Id, AccessDecision: client.NotAllowed}
+		if strings.HasSuffix(a.Id, "/read") {
+			d.AccessDecision = client.Allowed
+			d.RoleAssignment = client.RoleAssignment{Id: "ra-" + authzReq.Subject.Attributes.ObjectId, RoleDefinitionId: "reader", Scope: authzReq.Resource.Id}
+		}
+		res.Value = append(res.Value, d)
+	}
+	return res, nil
+}
+
+func (c *reviewClient) CreateAuthorizationRequest(string, []string, string) (*client.AuthorizationRequest, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestPlanReview(t *testing.T) {
+	rows := []reviewRow{
+		{Subject: "s1", Resource: "r1", Action: "a/read"},
+		{Subject: "s1", Resource: "r1", Action: "a/write"},
+		{Subject: "s1", Resource: "r1", Action: "a/read"},
+		{Subject: "s1", Resource: "r1", Action: "a/delete"},
+		{Subject: "s2", Resource: "r1", Action: "a/read"},
+		{Subject: "s1", Resource: "r1", Action: "a/read", IsDataAction: true},
+	}
+	want := []reviewCall{
+		{Subject: "s1", Resource: "r1", Actions: []client.ActionInfo{{Id: "a/read"}, {Id: "a/write"}}},
+		{Subject: "s1", Resource: "r1", Actions: []client.ActionInfo{{Id: "a/delete"}}},
+		{Subject: "s2", Resource: "r1", Actions: []client.ActionInfo{{Id: "a/read"}}},
+		{Subject: "s1", Resource: "r1", Actions: []client.ActionInfo{{Id: "a/read", IsDataAction: true}}},
+	}
+	calls := planReview(rows, 2)
+	if diff := cmp.Diff(want, calls); diff != "" {
+		t.Errorf("incorrect plan: %v", diff)
+	}
+	if calls[2].key() == calls[3].key() {
+		t.Errorf("expected the data action call and the control action call to have different keys but got '%s'", calls[2].key())
+	}
+}
+
+func TestExecuteReviewResumes(t *testing.T) {
+	calls := planReview([]reviewRow{
+		{Subject: "s1", Resource: "r1", Action: "a/read"},
+		{Subject: "s2", Resource: "r1", Action: "a/read"},
+		{Subject: "s3", Resource: "r1", Action: "a/write"},
+	}, 50)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+
+	pdp := &reviewClient{failing: map[string]bool{"s2": true}}
+	results, err := executeReview(context.Background(), pdp, calls, reviewOptions{concurrency: 2, checkpoint: checkpoint})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if pdp.calls != 3 || results[1].Error == "" {
+		t.Fatalf("expected 3 calls with s2 failing but got %d calls, %v", pdp.calls, results)
+	}
+
+	// the rerun only retries the failed call
+	pdp = &reviewClient{}
+	results, err = executeReview(context.Background(), pdp, calls, reviewOptions{concurrency: 2, checkpoint: checkpoint})
+	if err != nil {
+		t.Fatalf("expected error to be 'nil' but got '%v'", err)
+	}
+	if pdp.calls != 1 {
+		t.Errorf("expected 1 call after resuming but got %d", pdp.calls)
+	}
+	for _, r := range results {
+		if r.Error != "" || len(r.Decisions) != 1 {
+			t.Errorf("expected every call to be completed but got %v", r)
+		}
+	}
+}
+
+func TestReviewCommand(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "matrix.json")
+	if err := os.WriteFile(input, []byte(`{"subjects":["s1","s2"],"resources":["/subscriptions/sub"],"actions":["a/read","a/write"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	csvInput := filepath.Join(dir, "rows.csv")
+	if err := os.WriteFile(csvInput, []byte("subject,resource,action\ns1,/subscriptions/sub,a/read\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"value":[{"actionId":"a/read","accessDecision":"Allowed","roleAssignment":{"id":"ra1","roleDefinitionId":"reader","scope":"/subscriptions/sub"}},{"actionId":"a/write","accessDecision":"NotAllowed"}]}`
+
+	for _, tt := range []struct {
+		desc          string
+		args          []string
+		wantCalls     int
+		wantClaimName string
+		want          []string
+	}{
+		{
+			desc:          "matrix input, csv report",
+			args:          []string{"-input", input},
+			wantCalls:     2,
+			wantClaimName: client.GroupExpansion,
+			want:          []string{"subject,resource,action,decision", "s1,/subscriptions/sub,a/read,Allowed,ra1,reader,/subscriptions/sub", "s2,/subscriptions/sub,a/write,NotAllowed"},
+		},
+		{
+			desc:          "matrix input, markdown report",
+			args:          []string{"-input", input, "-format", "markdown"},
+			wantCalls:     2,
+			wantClaimName: client.GroupExpansion,
+			want:          []string{"| subject | resource |", "| s2 | /subscriptions/sub | a/read | Allowed | ra1 |"},
+		},
+		{
+			desc:      "csv input, json report",
+			args:      []string{"-input", csvInput, "-format", "json", "-group-expansion=false"},
+			wantCalls: 1,
+			want:      []string{`"decision": "Allowed"`, `"roleAssignmentId": "ra1"`},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			requests := respondWith(t, body)
+			var stdout bytes.Buffer
+			args := append([]string{"review", "-endpoint", endpoint, "-concurrency", "1", "-rate", "0"}, tt.args...)
+			if err := run(context.Background(), args, &stdout); err != nil {
+				t.Fatalf("expected error to be 'nil' but got '%v'", err)
+			}
+			if len(*requests) != tt.wantCalls {
+				t.Errorf("expected %d calls but got %d", tt.wantCalls, len(*requests))
+			}
+			for _, req := range *requests {
+				if req.Subject.Attributes.ClaimName != tt.wantClaimName {
+					t.Errorf("expected claim name '%s' but got '%s'", tt.wantClaimName, req.Subject.Attributes.ClaimName)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("expected report to contain %q but got:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}